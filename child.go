@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+var (
+	childrenMu sync.Mutex
+	children   = make(map[*exec.Cmd]chan struct{})
+)
+
+// startChild starts cmd like cmd.Start, and keeps track of it until
+// waitChild so that killChildren can stop it.  It's for commands that run as
+// long as their loop does, like upower --monitor, which would otherwise
+// outlive a restart and pile up with every config change.
+func startChild(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	childrenMu.Lock()
+	children[cmd] = make(chan struct{})
+	childrenMu.Unlock()
+	return nil
+}
+
+// waitChild waits for cmd, started by startChild, to exit like cmd.Wait, and
+// stops keeping track of it.
+func waitChild(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	childrenMu.Lock()
+	if done, ok := children[cmd]; ok {
+		close(done)
+		delete(children, cmd)
+	}
+	childrenMu.Unlock()
+	return err
+}
+
+// killChildren kills every command started by startChild that's still
+// running, and gives the loops that started them up to timeout to notice and
+// wait for them.
+func killChildren(timeout time.Duration) {
+	childrenMu.Lock()
+	var dones []chan struct{}
+	for cmd, done := range children {
+		if err := cmd.Process.Kill(); err != nil {
+			log.Printf("%s: %s", cmd.Path, err)
+		}
+		dones = append(dones, done)
+	}
+	childrenMu.Unlock()
+
+	deadline := time.After(timeout)
+	for _, done := range dones {
+		select {
+		case <-done:
+		case <-deadline:
+			return
+		}
+	}
+}
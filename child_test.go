@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestKillChildrenStopsRunningCommands(t *testing.T) {
+	cmd := exec.Command("sleep", "60")
+	if err := startChild(cmd); err != nil {
+		t.Skip(err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		waitChild(cmd)
+		close(exited)
+	}()
+
+	killChildren(time.Second)
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("sleep is still running after killChildren")
+	}
+
+	childrenMu.Lock()
+	defer childrenMu.Unlock()
+	if len(children) != 0 {
+		t.Errorf("%d children still tracked", len(children))
+	}
+}
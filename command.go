@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// A command is one line read with -stdin, of the form
+//
+//	verb loop [args...]
+//
+// such as "refresh power" or "toggle thermal".  It goes to every instance of
+// the named loop that listens for commands, which decides what the verb and
+// arguments mean.  Loops that listen understand at least "refresh", which
+// makes them update straight away.
+type command struct {
+	verb string
+	loop string
+	args []string
+}
+
+var (
+	listenersMu sync.Mutex
+	listeners   = make(map[string][]chan command)
+)
+
+// listen returns the channel on which commands for loop arrive.  Commands
+// that come in while an earlier one is still waiting are dropped rather than
+// held up behind a busy loop.
+func listen(loop string) <-chan command {
+	ch := make(chan command, 1)
+
+	listenersMu.Lock()
+	listeners[loop] = append(listeners[loop], ch)
+	listenersMu.Unlock()
+
+	return ch
+}
+
+// parseCommand splits line into a command.  It reports false for a line that
+// has no verb and loop, or is blank.
+func parseCommand(line string) (command, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return command{}, false
+	}
+	return command{verb: fields[0], loop: fields[1], args: fields[2:]}, true
+}
+
+// dispatch hands c to every loop listening for it.
+func dispatch(c command) {
+	listenersMu.Lock()
+	chs := listeners[c.loop]
+	listenersMu.Unlock()
+
+	if len(chs) == 0 {
+		log.Printf("No %s loop takes commands", c.loop)
+		return
+	}
+
+	for _, ch := range chs {
+		select {
+		case ch <- c:
+		default:
+			log.Printf("%s is busy, dropping %q", c.loop, c.verb)
+		}
+	}
+}
+
+// readCommands dispatches the commands read from r, one per line, until it
+// runs out.
+func readCommands(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		c, ok := parseCommand(line)
+		if !ok {
+			log.Printf("Can't parse command %q", line)
+			continue
+		}
+		dispatch(c)
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Print(err)
+	}
+	log.Print("No more commands on stdin")
+}
+
+// unknown logs that the loop got a command it doesn't understand.
+func (c command) unknown() {
+	log.Printf("%s doesn't understand %q", c.loop, c.verb)
+}
@@ -0,0 +1,537 @@
+package main
+
+import "time"
+
+// Config holds the settings that control what each segment shows.  The
+// defaults live in config below.  The JSON config file, given with -config,
+// overrides whichever of them it mentions, using the field names below, e.g.
+//
+//	{"Compact": true, "Thermal": {"Source": "zones"}, "Disk": {"Interval": "5m"}}
+//
+// Lists, and entries of maps of settings such as Profiles, replace the
+// defaults as a whole rather than being merged with them.
+type Config struct {
+	// Compact makes loops that support it render shortened text, for bars
+	// that are too narrow to fit everything.
+	Compact bool
+
+	// StaleColor is the color, named or raw as in SegmentConfig.Color, that
+	// a cached value is shown in while it's being re-displayed because
+	// fetching a fresh one failed.  Empty shows it unchanged.
+	StaleColor string
+
+	// BinaryUnits makes loops that show sizes or rates, such as diskio,
+	// use 1024-based units (MiB, as in free -m) instead of 1000-based ones
+	// (MB).  Loops that only show percentages, like memory and swap, are
+	// unaffected.
+	BinaryUnits bool
+
+	// DurationStyle is how loops show lengths of time, such as how long
+	// until the battery is empty: "compact" (1h05m), "clock" (1:05) or
+	// "spaced" (1h 5m).
+	DurationStyle string
+
+	// Remote maps loop names to a command prefix that the commands the loop
+	// runs are passed to, such as {"ssh", "server", "--"}, so the loop shows
+	// another host.  Only the thermal loop's acpi and sensors sources
+	// support this, and Thermal.EmergencyCommand runs on the remote host
+	// too; sysfs sources are always read locally.  While the host can't be
+	// reached the last reading is shown in StaleColor for up to
+	// RemoteMaxAge.  A read that takes longer than RemoteTimeout, say over a
+	// hung connection, is given up on and counts as failed.
+	Remote        map[string][]string
+	RemoteMaxAge  time.Duration
+	RemoteTimeout time.Duration
+
+	// Output is the command run to display the status, with %s standing for
+	// the status text.  It's only used if Sinks is empty.
+	Output []string
+
+	// Sinks lists everywhere the status is shown, all at once, such as
+	// both the root window and a file.
+	Sinks []SinkConfig
+
+	// Segments lists what appears on the bar, from left to right.
+	Segments []SegmentConfig
+
+	// WaitForAll, if non-zero, holds back showing the bar at startup until
+	// every segment has reported or this long has passed, so it doesn't
+	// appear with placeholders that fill in one by one.
+	WaitForAll time.Duration
+
+	// Labels maps loop names to the text put in front of their values, e.g.
+	// "RAM: " for memory.  Icons does the same with glyphs, such as Nerd
+	// Font icons, and takes precedence over Labels; an icon is separated
+	// from the value by a space.
+	Labels map[string]string
+	Icons  map[string]string
+
+	// MaxLength, if non-zero, limits the status to that many characters, not
+	// counting color escapes.  Truncate says how to make it fit: "ellipsis"
+	// cuts the end off, while "drop" first leaves out whole segments,
+	// lowest Priority first.
+	MaxLength int
+	Truncate  string
+
+	// BlinkColor and BlinkInterval control how segments set to blink
+	// flash: they alternate between critical and BlinkColor, switching
+	// every BlinkInterval.
+	BlinkColor    string
+	BlinkInterval time.Duration
+
+	// Profiles holds named sets of thresholds and colors, and Schedule says
+	// which one is in effect when.  The schedule is followed by the time
+	// loop, so it only switches profiles while the bar has a time segment.
+	// With no schedule the "day" profile is always used.  For instance
+	//
+	//	Schedule: []ProfileSwitch{{7, "day"}, {22, "night"}}
+	//
+	// switches to a "night" profile at 22:00 and back at 07:00.
+	Profiles map[string]Profile
+	Schedule []ProfileSwitch
+
+	Power      PowerConfig
+	Thermal    ThermalConfig
+	Brightness BrightnessConfig
+	DiskIO     DiskIOConfig
+	Memory     MemoryConfig
+	Display    DisplayConfig
+	Swap       SwapConfig
+	Mic        MicConfig
+	Network    NetworkConfig
+	Updates    UpdatesConfig
+	Title      TitleConfig
+	Disk       DiskConfig
+	Services   ServicesConfig
+	Proc       ProcConfig
+	Idle       IdleConfig
+	Git        GitConfig
+
+	// Counters configures the counter loops by name, so that e.g. a segment
+	// with Loop "counter:irc" shows Counters["irc"].
+	Counters map[string]CounterConfig
+}
+
+// SinkConfig describes one place the status is shown.
+type SinkConfig struct {
+	// Type is one of "command", which runs Command as with Config.Output,
+	// "stdout", "file", which writes the status to Path, and "http", which
+	// POSTs it to URL.
+	Type    string
+	Command []string
+	Path    string
+	URL     string
+
+	// Append makes a file sink add each status to the end of the file,
+	// timestamped, instead of replacing its contents.
+	Append bool
+}
+
+// SegmentConfig places the output of one loop on the bar.
+type SegmentConfig struct {
+	// Loop names the loop that produces the text, as listed in loops, or a
+	// counter as "counter:" followed by its name in Config.Counters.
+	Loop string
+
+	// Width, if non-zero, pads the text with spaces to at least that many
+	// characters so the rest of the bar doesn't shift as it changes.  A
+	// positive width right-aligns the text and a negative one left-aligns
+	// it.
+	Width int
+
+	// Color, if set, colors the whole segment: either "normal", "warning",
+	// "critical", or a raw dwm color escape such as "\x05".  Colors the loop
+	// puts in its own text still take precedence.
+	Color string
+
+	// Priority decides which segments are left out first when the status is
+	// too long and Truncate is "drop": lower priorities go first.
+	Priority int
+
+	// Blink makes the segment flash between critical and Config.BlinkColor
+	// while the loop reports something critical, such as a nearly empty
+	// battery.
+	Blink bool
+
+	// Delay holds back starting the loop, so that loops started together
+	// don't all run their commands at the same moment.  Until it starts the
+	// segment shows a placeholder.
+	Delay time.Duration
+}
+
+// A Profile is a set of thresholds and colors that can be switched between
+// on a schedule.
+type Profile struct {
+	// Colors maps colors, by name or escape as in SegmentConfig.Color, to
+	// the ones drawn instead, e.g. {"critical": "\x05"} for a dimmer red.
+	Colors map[string]string
+
+	// ThermalWarning and ThermalCritical are the temperatures, in degrees
+	// F, at which the thermal segment turns warning and critical.  A
+	// profile that leaves them out uses the "day" profile's.
+	ThermalWarning  float64
+	ThermalCritical float64
+
+	// BrightnessWarning, if non-zero, shows the brightness as a warning
+	// once it's above this percentage.
+	BrightnessWarning float64
+}
+
+// A ProfileSwitch makes Profile the active profile from Hour o'clock on.
+type ProfileSwitch struct {
+	Hour    int
+	Profile string
+}
+
+// PowerConfig controls powerLoop.
+type PowerConfig struct {
+	// Source selects where battery information comes from: "acpi" reads the
+	// first battery acpi reports, "sysfs" combines every battery under
+	// /sys/class/power_supply.
+	Source string
+
+	// Full is the label shown when acpi reports the battery as full.
+	Full string
+
+	// NotCharging is the label shown when the battery is plugged in but
+	// neither charging nor discharging, as happens when a charge threshold
+	// holds it below full.
+	NotCharging string
+
+	// Icons replaces the textual battery status with a glyph from Glyphs
+	// chosen by charge level, followed by ChargingGlyph while charging or
+	// HeldGlyph while held below full.  It has no effect if Glyphs is empty.
+	Icons         bool
+	Glyphs        []string
+	ChargingGlyph string
+	HeldGlyph     string
+
+	// ShowUntil shows the time of day the battery is expected to be empty
+	// or full ("until 14:30") instead of how long that will take.
+	ShowUntil bool
+
+	// HideChargingTime and HideDischargingTime leave out the time estimate
+	// while the battery is charging or discharging respectively, showing
+	// just the percentage.
+	HideChargingTime    bool
+	HideDischargingTime bool
+}
+
+// ThermalConfig controls thermalLoop.
+type ThermalConfig struct {
+	// Source selects where the temperature comes from: "acpi" reads the
+	// first zone through acpi, "zones" reads every zone under
+	// /sys/class/thermal and reports the hottest, and "sensors" reads
+	// Feature of Chip from lm-sensors.
+	Source string
+
+	// Chip and Feature pick the temperature the "sensors" source reports,
+	// as named by sensors -u: Feature is e.g. "Package id 0", and Chip is
+	// the start of the chip's name, e.g. "coretemp", or empty for any chip.
+	Chip    string
+	Feature string
+
+	// Zones, if non-empty, restricts the "zones" source to the listed zone
+	// names (thermal_zone0) or types (x86_pkg_temp).
+	Zones []string
+
+	// Celsius shows the temperature in degrees C instead of F.  Sending
+	// dwmstatus SIGUSR1 switches between the two.  Limits such as
+	// EmergencyLimit are in degrees F either way.
+	Celsius bool
+
+	// ShowZone prefixes the temperature with the type of the zone it came
+	// from.
+	ShowZone bool
+
+	// Deadband, if non-zero, holds back new readings until they differ from
+	// the last one shown by more than this many degrees.
+	Deadband float64
+
+	// EmergencyCommand, if set, is run once each time the temperature
+	// reaches EmergencyLimit degrees F, e.g. to suspend the machine before it
+	// overheats.
+	EmergencyCommand []string
+	EmergencyLimit   float64
+
+	// TrendDeadband is how far, in degrees, a reading must move from the
+	// previous one before the trend arrow shows it rising or falling.
+	TrendDeadband float64
+}
+
+// BrightnessConfig controls brightnessLoop.
+type BrightnessConfig struct {
+	// Debounce is how long the backlight must stay unchanged before the new
+	// brightness is read.
+	Debounce time.Duration
+
+	// Gamma, if non-zero, maps the backlight level through a gamma curve
+	// so the percentage shown follows perceived brightness, which at low
+	// levels rises much faster than the hardware value.  Around 2.2 suits
+	// most panels; 0 or 1 shows the raw, linear percentage.
+	Gamma float64
+}
+
+// DiskIOConfig controls diskioLoop.
+type DiskIOConfig struct {
+	// Device is the block device to report on, as named in /proc/diskstats.
+	Device string
+
+	// Interval is how often the read and write rates are sampled.
+	Interval time.Duration
+}
+
+// MemoryConfig controls memoryLoop.
+type MemoryConfig struct {
+	// Deadband, if non-zero, holds back new readings until they differ from
+	// the last one shown by more than this many percentage points.
+	Deadband float64
+}
+
+// DisplayConfig controls displayLoop.
+type DisplayConfig struct {
+	// Interval is how often xrandr is polled for connected outputs.
+	Interval time.Duration
+}
+
+// SwapConfig controls swapLoop.
+type SwapConfig struct {
+	// Interval is how often swap usage and paging are sampled.
+	Interval time.Duration
+
+	// Thrashing is the number of pages per second swapped in and out at
+	// which the segment turns critical.
+	Thrashing float64
+}
+
+// MicConfig controls micLoop.
+type MicConfig struct {
+	// Glyph is shown while something is recording from a microphone.
+	Glyph string
+
+	// Interval is how often capture streams are polled.  Changes reported
+	// by pactl subscribe are picked up immediately regardless.
+	Interval time.Duration
+}
+
+// NetworkConfig controls networkLoop.
+type NetworkConfig struct {
+	// Smoothing, if between 0 and 1, shows an exponential moving average of
+	// the throughput instead of the raw rates, giving each new sample this
+	// much weight.  Smaller values are smoother but slower to react.
+	Smoothing float64
+
+	// IdleBelow, if non-zero, shows IdleText instead of the rates while
+	// both are below this many KB/s, rather than flickering between tiny
+	// values.  An empty IdleText leaves the segment out meanwhile.
+	IdleBelow float64
+	IdleText  string
+}
+
+// UpdatesConfig controls updatesLoop.
+type UpdatesConfig struct {
+	// Command lists available updates, one per line, e.g. checkupdates on
+	// Arch or apt list --upgradable on Debian.
+	Command []string
+
+	// Match, if set, is a regular expression that lines of Command's output
+	// must match to be counted, e.g. "upgradable from" to skip apt's
+	// "Listing..." header.
+	Match string
+
+	// Interval is how often to check for updates.
+	Interval time.Duration
+
+	// MaxAge is how long the last count keeps being shown, in
+	// Config.StaleColor, while checks fail, e.g. because the network is
+	// down.
+	MaxAge time.Duration
+}
+
+// TitleConfig controls titleLoop.
+type TitleConfig struct {
+	// MaxLength, if non-zero, is the most characters of the title to show
+	// before cutting it off with an ellipsis.
+	MaxLength int
+}
+
+// DiskConfig controls diskLoop.
+type DiskConfig struct {
+	// Mounts lists the mount points to show free space for.
+	Mounts []DiskMount
+
+	// Interval is how often free space is checked.
+	Interval time.Duration
+}
+
+// DiskMount is one mount point shown by diskLoop.
+type DiskMount struct {
+	Path string
+
+	// Warn is the percentage of free space below which the disk segment
+	// is shown as critical.
+	Warn float64
+}
+
+// ServicesConfig controls servicesLoop.
+type ServicesConfig struct {
+	// Units lists the systemd units that are expected to be active, e.g.
+	// "docker.service".
+	Units []string
+
+	// User checks the units of the user's own systemd instance instead of
+	// the system's.
+	User bool
+
+	// Interval is how often the units are checked.
+	Interval time.Duration
+}
+
+// ProcConfig controls procLoop.
+type ProcConfig struct {
+	// Source selects how processes are counted: "loadavg" reads the total
+	// from /proc/loadavg, which is cheap but includes threads, while
+	// "count" counts the processes in /proc.
+	Source string
+
+	// Interval is how often processes are counted.
+	Interval time.Duration
+}
+
+// IdleConfig controls idleLoop.
+type IdleConfig struct {
+	// Threshold is how long the session must go without input before the
+	// segment shows anything.
+	Threshold time.Duration
+
+	// Format is the fmt format the idle time is shown with, %s standing for
+	// the time.  Marker, if set, is shown instead, e.g. a glyph warning that
+	// the screen is about to lock.
+	Format string
+	Marker string
+
+	// Interval is how often xprintidle is polled.
+	Interval time.Duration
+}
+
+// GitConfig controls gitLoop.
+type GitConfig struct {
+	// Repo is the path of the git repository whose branch is shown.  If
+	// it's empty, nothing is.
+	Repo string
+}
+
+// CounterConfig controls one instance of counterLoop, which shows a number
+// kept in a file by an external notifier, such as a count of unread messages.
+type CounterConfig struct {
+	// Path is the file holding the number.
+	Path string
+
+	// Label is put in front of the number, e.g. "irc " or a glyph.  Nothing
+	// at all is shown while the number is zero.
+	Label string
+}
+
+var config = Config{
+	Output:        []string{"xsetroot", "-name", "%s"},
+	DurationStyle: "compact",
+	RemoteMaxAge:  5 * time.Minute,
+	RemoteTimeout: 10 * time.Second,
+	Truncate:      "ellipsis",
+	BlinkColor:    "normal",
+	BlinkInterval: time.Second,
+	Labels: map[string]string{
+		"brightness": "\u2600",
+		"diskio":     "io ",
+		"git":        "\ue0a0 ",
+		"memory":     "RAM: ",
+		"proc":       "proc ",
+		"services":   "svc: ",
+		"swap":       "swap ",
+		"updates":    "\u27f3 ",
+	},
+	Profiles: map[string]Profile{
+		"day": {
+			ThermalWarning:  176,
+			ThermalCritical: 185,
+		},
+	},
+	Segments: []SegmentConfig{
+		{Loop: "power"},
+		{Loop: "brightness"},
+		{Loop: "network"},
+		{Loop: "thermal"},
+		{Loop: "memory"},
+		{Loop: "time"},
+	},
+	Power: PowerConfig{
+		Source:      "acpi",
+		Full:        "charged",
+		NotCharging: "held",
+		Glyphs: []string{
+			"\uf244", // empty
+			"\uf243", // quarter
+			"\uf242", // half
+			"\uf241", // three quarters
+			"\uf240", // full
+		},
+		ChargingGlyph: "\uf0e7",
+		HeldGlyph:     "\uf1e6",
+	},
+	Thermal: ThermalConfig{
+		Source:         "acpi",
+		Feature:        "Package id 0",
+		TrendDeadband:  0.5,
+		EmergencyLimit: 203,
+	},
+	Brightness: BrightnessConfig{
+		Debounce: 100 * time.Millisecond,
+	},
+	DiskIO: DiskIOConfig{
+		Device:   "sda",
+		Interval: time.Second,
+	},
+	Memory: MemoryConfig{
+		Deadband: 2,
+	},
+	Display: DisplayConfig{
+		Interval: 5 * time.Second,
+	},
+	Swap: SwapConfig{
+		Interval:  time.Second,
+		Thrashing: 100,
+	},
+	Mic: MicConfig{
+		Glyph:    "\uf130",
+		Interval: 2 * time.Second,
+	},
+	Network: NetworkConfig{
+		IdleText: "idle",
+	},
+	Updates: UpdatesConfig{
+		Command:  []string{"checkupdates"},
+		Interval: time.Hour,
+		MaxAge:   24 * time.Hour,
+	},
+	Title: TitleConfig{
+		MaxLength: 50,
+	},
+	Disk: DiskConfig{
+		Mounts: []DiskMount{
+			{Path: "/", Warn: 10},
+		},
+		Interval: time.Minute,
+	},
+	Services: ServicesConfig{
+		Interval: 10 * time.Second,
+	},
+	Proc: ProcConfig{
+		Source:   "loadavg",
+		Interval: 5 * time.Second,
+	},
+	Idle: IdleConfig{
+		Threshold: 5 * time.Minute,
+		Format:    "idle %s",
+		Interval:  5 * time.Second,
+	},
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultConfigPath returns where the config file is looked for unless -config
+// says otherwise, normally ~/.config/dwmstatus/config.json.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dwmstatus", "config.json")
+}
+
+// loadConfig reads the JSON config file at path over the defaults in config.
+// Only the settings the file mentions change, and a missing file changes
+// nothing.  Durations may be written either as strings such as "1m30s" or as
+// nanoseconds.
+func loadConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	tree, err = parseDurations(tree, reflect.TypeOf(config))
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	data, err = json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+
+	// Catch misspelled settings rather than silently ignoring them.
+	decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parseDurations replaces the strings in v, decoded JSON bound for a value of
+// type t, that will end up in time.Duration fields with their number of
+// nanoseconds, which is what encoding/json expects.
+func parseDurations(v interface{}, t reflect.Type) (interface{}, error) {
+	var err error
+
+	switch {
+	case t == durationType:
+		if s, ok := v.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, err
+			}
+			return int64(d), nil
+		}
+	case t.Kind() == reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key, value := range m {
+			// encoding/json matches field names regardless of case.
+			field, ok := t.FieldByNameFunc(func(name string) bool {
+				return strings.EqualFold(name, key)
+			})
+			if !ok {
+				continue
+			}
+			if m[key], err = parseDurations(value, field.Type); err != nil {
+				return nil, err
+			}
+		}
+	case t.Kind() == reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for key, value := range m {
+			if m[key], err = parseDurations(value, t.Elem()); err != nil {
+				return nil, err
+			}
+		}
+	case t.Kind() == reflect.Slice:
+		s, ok := v.([]interface{})
+		if !ok {
+			break
+		}
+		for i, value := range s {
+			if s[i], err = parseDurations(value, t.Elem()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// watchConfig restarts dwmstatus whenever the config file at path changes, so
+// the new settings take effect.  Loops can't be stopped and rebuilt in place,
+// so it re-executes the binary instead.  A config that doesn't pass -check is
+// logged and otherwise ignored, keeping the running bar as it is.
+func watchConfig(path string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	defer watcher.Close()
+
+	// Editors usually save by replacing the file, so watch its directory.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		log.Printf("Not watching %s for changes: %s", path, err)
+		return
+	}
+
+	isConfig := func(name string) bool {
+		return filepath.Base(name) == filepath.Base(path)
+	}
+
+	for range debounce(watchEvents(watcher, isConfig), 200*time.Millisecond) {
+		restart(path)
+	}
+	log.Printf("Stopped watching %s for changes", path)
+}
+
+// restart re-executes dwmstatus with the same arguments, after making sure
+// the config file at path is usable.
+func restart(path string) {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	args := append([]string{"-check"}, os.Args[1:]...)
+	if out, err := exec.Command(exe, args...).CombinedOutput(); err != nil {
+		log.Printf("Not reloading %s: %s", path, bytes.TrimSpace(out))
+		return
+	}
+
+	log.Printf("%s changed, restarting", path)
+
+	// The new process starts its own, so don't leave these running.
+	killChildren(time.Second)
+	if err := syscall.Exec(exe, os.Args, os.Environ()); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// counterPrefix starts the loop names of counter instances: "counter:irc"
+// shows config.Counters["irc"].
+const counterPrefix = "counter:"
+
+// lookupLoop returns the function for the loop named in a segment's config,
+// which is either one of loops or a counter instance.
+func lookupLoop(name string) (func(chan<- Segment), bool) {
+	if strings.HasPrefix(name, counterPrefix) {
+		counter, ok := config.Counters[strings.TrimPrefix(name, counterPrefix)]
+		if !ok {
+			return nil, false
+		}
+		return func(ch chan<- Segment) {
+			counterLoop(ch, counter)
+		}, true
+	}
+
+	f, ok := loops[name]
+	return f, ok
+}
+
+// readCounter reads the count in path.  A file that doesn't exist yet counts
+// as zero, since the notifier writing it may not have run.
+func readCounter(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return n, nil
+}
+
+// counterLoop shows the number in counter.Path, re-reading it whenever it
+// changes, and shows nothing while it's zero.
+func counterLoop(ch chan<- Segment, counter CounterConfig) {
+	r := reporter{ch: ch}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.fail(err)
+		return
+	}
+	defer watcher.Close()
+
+	// Notifiers often replace the file rather than writing to it, which a
+	// watch on the file itself wouldn't survive, so watch its directory.
+	path := filepath.Clean(counter.Path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		r.fail(err)
+		return
+	}
+
+	update := func() {
+		n, err := readCounter(path)
+		if err != nil {
+			r.fail(err)
+			return
+		}
+
+		if n == 0 {
+			r.send("")
+		} else {
+			r.send(fmt.Sprintf("%s%d", counter.Label, n))
+		}
+	}
+
+	update()
+
+	isCounter := func(name string) bool {
+		return filepath.Clean(name) == path
+	}
+
+	for range debounce(watchEvents(watcher, isCounter), 100*time.Millisecond) {
+		update()
+	}
+	r.fail(fmt.Errorf("%s: watcher stopped", path))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// diskFree returns the percentage of the filesystem mounted at path that is
+// available to unprivileged users.
+func diskFree(path string) (float64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, fmt.Errorf("statfs %s: %s", path, err)
+	}
+	if st.Blocks == 0 {
+		return 0, fmt.Errorf("statfs %s: no blocks", path)
+	}
+	return 100 * float64(st.Bavail) / float64(st.Blocks), nil
+}
+
+// diskLoop shows how much space is free on each of config.Disk.Mounts, and is
+// critical while any mount's free space is below that mount's threshold.
+func diskLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	for range eagerTick(context.Background(), config.Disk.Interval) {
+		parts := make([]string, 0, len(config.Disk.Mounts))
+		level := LevelNormal
+		var err error
+
+		for _, mount := range config.Disk.Mounts {
+			var free float64
+			free, err = diskFree(mount.Path)
+			if err != nil {
+				break
+			}
+
+			if free < mount.Warn {
+				level = LevelCritical
+			}
+			parts = append(parts, fmt.Sprintf("%s %.0f%%", mount.Path, free))
+		}
+
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		r.sendSegment(Segment{Text: strings.Join(parts, " "), Level: level})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+)
+
+// diskstats counts sectors in units of 512 bytes regardless of the device's
+// actual sector size.
+const sectorSize = 512
+
+// readDiskSectors returns the number of sectors read from and written to
+// device since boot, according to /proc/diskstats.
+func readDiskSectors(device string) (read, written uint64, err error) {
+	data, err := ioutil.ReadFile("/proc/diskstats")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fields := bytes.Fields(line)
+		if len(fields) < 10 || string(fields[2]) != device {
+			continue
+		}
+
+		read, err := strconv.ParseUint(string(fields[5]), 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		written, err := strconv.ParseUint(string(fields[9]), 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return read, written, nil
+	}
+
+	return 0, 0, fmt.Errorf("device %q not found in /proc/diskstats", device)
+}
+
+func diskioLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	diskioFormats := formats{"%.1f/%.1f %s/s", "%.1[1]f/%.1[2]f"}
+
+	var lastRead, lastWritten uint64
+	var lastTime time.Time
+
+	for range eagerTick(context.Background(), config.DiskIO.Interval) {
+		read, written, err := readDiskSectors(config.DiskIO.Device)
+		if err != nil {
+			r.fail(err)
+			lastTime = time.Time{}
+			continue
+		}
+
+		now := time.Now()
+
+		if !lastTime.IsZero() {
+			seconds := now.Sub(lastTime).Seconds()
+			readMB, unit := megabytes(float64(read-lastRead) * sectorSize / seconds)
+			writtenMB, _ := megabytes(float64(written-lastWritten) * sectorSize / seconds)
+			r.send(diskioFormats.sprintf(readMB, writtenMB, unit))
+		}
+
+		lastRead, lastWritten, lastTime = read, written, now
+	}
+}
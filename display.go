@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var xrandrOutputRe = regexp.MustCompile(`^(\S+) connected( primary)?(?: (\d+x\d+)\+\d+\+\d+)?`)
+
+// displaySummary describes the outputs xrandr reports as connected, e.g.
+// "2x HDMI 2560x1440", naming the primary output and its resolution.  If no
+// output is marked primary, the first one that is on is used.
+func displaySummary(out []byte) (string, error) {
+	count := 0
+	var name, resolution string
+	havePrimary := false
+
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		m := xrandrOutputRe.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+		count++
+
+		primary := m[2] != nil
+		if havePrimary || !(primary || (name == "" && m[3] != nil)) {
+			continue
+		}
+
+		// Drop the connector index, e.g. HDMI-1 becomes HDMI.
+		name = strings.TrimRight(string(m[1]), "-0123456789")
+		resolution = string(m[3])
+		havePrimary = primary
+	}
+
+	if count == 0 {
+		return "", errors.New("xrandr reports no connected outputs")
+	}
+	if name == "" {
+		return fmt.Sprintf("%dx off", count), nil
+	}
+	return strings.TrimSpace(fmt.Sprintf("%dx %s %s", count, name, resolution)), nil
+}
+
+func displayLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	for range eagerTick(context.Background(), config.Display.Interval) {
+		out, err := exec.Command("xrandr", "--query").Output()
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		summary, err := displaySummary(out)
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		r.send(summary)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// gitOutput runs git with args in config.Git.Repo and returns its trimmed
+// output.  --no-optional-locks keeps status from rewriting the index, which
+// gitLoop would otherwise see as a change.
+func gitOutput(args ...string) (string, error) {
+	args = append([]string{"--no-optional-locks", "-C", config.Git.Repo}, args...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[3], err)
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// gitStatus returns the repo's branch, or its abbreviated commit if HEAD is
+// detached, followed by "*" if the work tree has changes.
+func gitStatus() (string, error) {
+	branch, err := gitOutput("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		branch, err = gitOutput("rev-parse", "--short", "HEAD")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	changes, err := gitOutput("status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	if changes != "" {
+		branch += "*"
+	}
+	return branch, nil
+}
+
+// gitLoop shows the branch of config.Git.Repo, updating when HEAD or the
+// index changes, as they do on checkouts, commits and adds.
+func gitLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	if config.Git.Repo == "" {
+		r.send("")
+		return
+	}
+
+	gitDir, err := gitOutput("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		r.fail(err)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.fail(err)
+		return
+	}
+	defer watcher.Close()
+
+	// HEAD and the index are replaced rather than written to, so watch the
+	// directory they're in.
+	if err := watcher.Add(gitDir); err != nil {
+		r.fail(err)
+		return
+	}
+
+	update := func() {
+		status, err := gitStatus()
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		r.send(status)
+	}
+
+	update()
+
+	isRef := func(name string) bool {
+		name = filepath.Base(name)
+		return name == "HEAD" || name == "index"
+	}
+
+	// A commit or checkout touches both files in quick succession.
+	for range debounce(watchEvents(watcher, isRef), 200*time.Millisecond) {
+		update()
+	}
+	r.fail(errors.New("git watcher stopped"))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// idleTime asks xprintidle how long it's been since the last keyboard or
+// mouse input.
+func idleTime() (time.Duration, error) {
+	out, err := exec.Command("xprintidle").Output()
+	if err != nil {
+		return 0, err
+	}
+
+	ms, err := strconv.ParseInt(string(bytes.TrimSpace(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("xprintidle returned unexpected output: %q", out)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// idleLoop shows how long the session has been idle once that's at least
+// config.Idle.Threshold, and nothing while it's in use.
+func idleLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	for range eagerTick(context.Background(), config.Idle.Interval) {
+		idle, err := idleTime()
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		switch {
+		case idle < config.Idle.Threshold:
+			r.send("")
+		case config.Idle.Marker != "":
+			r.send(config.Idle.Marker)
+		default:
+			r.send(fmt.Sprintf(config.Idle.Format, formatDuration(idle, config.DurationStyle)))
+		}
+	}
+}
@@ -3,33 +3,74 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"os/exec"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
+// Build information, set with e.g.
+//
+//	go install -ldflags "-X main.version=1.2 -X main.commit=$(git rev-parse HEAD)"
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
 // eagerTick is like time.Tick, but it also includes a tick that fires
-// immediately.
-func eagerTick(interval time.Duration) <-chan time.Time {
-	ch := make(chan time.Time)
+// immediately.  Once ctx is done the underlying ticker is stopped and the
+// returned channel is closed.
+//
+// Like time.Tick, ticks are dropped rather than queued for a slow receiver:
+// the channel holds at most one pending tick, and any tick that arrives while
+// one is pending is discarded.
+func eagerTick(ctx context.Context, interval time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
 	go func() {
-		ch <- time.Now()
-		for t := range time.Tick(interval) {
-			ch <- t
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		t := time.Now()
+		for {
+			select {
+			case ch <- t:
+			default:
+			}
+
+			select {
+			case t = <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 	return ch
 }
 
+// debounce waits for ch to go quiet for interval, then sends the time of the
+// last value it received.
+//
+// The returned channel holds at most one pending value.  If the receiver
+// hasn't taken it by the time the next burst settles, the new value is dropped
+// and the pending one kept, so a slow receiver never stalls debounce or
+// whatever is feeding ch.  Once ch is closed, so is the returned channel.
 func debounce(ch <-chan time.Time, interval time.Duration) <-chan time.Time {
-	outCh := make(chan time.Time)
+	outCh := make(chan time.Time, 1)
 
 	go func() {
 		var lastTime time.Time
@@ -38,7 +79,14 @@ func debounce(ch <-chan time.Time, interval time.Duration) <-chan time.Time {
 
 		for {
 			select {
-			case t := <-ch:
+			case t, ok := <-ch:
+				if !ok {
+					if timer != nil {
+						timer.Stop()
+					}
+					close(outCh)
+					return
+				}
 				lastTime = t
 				if timer != nil {
 					timer.Stop()
@@ -49,7 +97,10 @@ func debounce(ch <-chan time.Time, interval time.Duration) <-chan time.Time {
 				timer = time.NewTimer(interval)
 				timerCh = timer.C
 			case <-timerCh:
-				outCh <- lastTime
+				select {
+				case outCh <- lastTime:
+				default:
+				}
 			}
 		}
 	}()
@@ -57,156 +108,248 @@ func debounce(ch <-chan time.Time, interval time.Duration) <-chan time.Time {
 	return outCh
 }
 
-func thermalLoop(ch chan<- string) {
-	re := regexp.MustCompile(`Thermal 0: ok, ([.0-9]+) degrees F`)
+// watchEvents sends the time of each of w's events on a file whose name match
+// accepts, or of every event if match is nil, and logs w's errors.  The
+// returned channel is closed if w stops delivering events.
+func watchEvents(w *fsnotify.Watcher, match func(name string) bool) <-chan time.Time {
+	ch := make(chan time.Time)
 
-	for range eagerTick(time.Second) {
-		out, err := exec.Command("acpi", "--thermal", "--fahrenheit").Output()
-		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
-			continue
-		}
+	go func() {
+		defer close(ch)
 
-		m := re.FindSubmatch(out)
-		if m == nil {
-			log.Printf("acpi returned unexpected output: %q", out)
-			ch <- "(err)"
-			continue
+		errs := w.Errors
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if match == nil || match(event.Name) {
+					ch <- time.Now()
+				}
+			case err, ok := <-errs:
+				if !ok {
+					log.Print("watcher errors chan closed")
+					errs = nil
+				} else {
+					log.Print(err)
+				}
+			}
 		}
+	}()
 
-		tempF, err := strconv.ParseFloat(string(m[1]), 64)
-		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
-			continue
-		}
+	return ch
+}
 
-		if tempF >= 185 {
-			ch <- fmt.Sprintf("\x04%.1f \u00b0F", tempF)
-		} else if tempF >= 176 {
-			ch <- fmt.Sprintf("\x03%.1f \u00b0F", tempF)
-		} else {
-			ch <- fmt.Sprintf("%.1f \u00b0F", tempF)
-		}
+// A cache keeps a loop's last good segment, so that when fetching a new one
+// fails, e.g. because of a flaky network, the loop can keep showing the old
+// one for up to maxAge instead of "(err)".
+type cache struct {
+	maxAge time.Duration
+	last   Segment
+	at     time.Time
+	set    bool
+}
+
+// store remembers s as the latest good segment.
+func (c *cache) store(s Segment) {
+	c.last, c.at, c.set = s, time.Now(), true
+}
+
+// stale returns the remembered segment, in config.StaleColor, if there is one
+// and it's no older than maxAge.
+func (c *cache) stale() (Segment, bool) {
+	if !c.set || time.Since(c.at) >= c.maxAge {
+		return Segment{}, false
+	}
+	// Coloring nothing would leave a bare escape, which render would then
+	// show as a segment with just its label.
+	if config.StaleColor == "" || c.last.Text == "" {
+		return c.last, true
 	}
+
+	escape, _ := colorEscape(config.StaleColor)
+	return Segment{Text: escape + c.last.Text, Priority: c.last.Priority}, true
 }
 
-func powerLoop(ch chan<- string) {
-	updateCh := make(chan time.Time)
+// A reporter sends a loop's segments to main and reports the loop's errors as
+// "(err)".  A loop that keeps failing is only logged and reported once per
+// distinct error, rather than on every tick, until it has something else to
+// send.
+type reporter struct {
+	ch      chan<- Segment
+	lastErr string
+	failing bool
+}
 
-	go func() {
-		// Whenever upower --monitor detects a change, we'll want to update the
-		// power text.
-		cmd := exec.Command("upower", "--monitor")
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			log.Print(err)
-			return
-		}
-		defer stdout.Close()
-		if err := cmd.Start(); err != nil {
-			log.Print(err)
-			return
-		}
+// send sends text as a segment, ending any run of errors.
+func (r *reporter) send(text string) {
+	r.sendSegment(textSegment(text))
+}
 
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			updateCh <- time.Now()
-		}
-	}()
+// sendSegment sends s, ending any run of errors.
+func (r *reporter) sendSegment(s Segment) {
+	r.lastErr, r.failing = "", false
+	r.ch <- s
+}
 
-	go func() {
-		for t := range eagerTick(time.Minute) {
-			updateCh <- t
-		}
-	}()
+// logErr logs err, unless it's the same as the last one.
+func (r *reporter) logErr(err error) {
+	if msg := err.Error(); msg != r.lastErr {
+		log.Print(err)
+		r.lastErr = msg
+	}
+}
 
-	re := regexp.MustCompile(`Battery 0: (Unknown|Charging|Discharging), (\d+)%(, (\d+):(\d+):(\d+))?`)
+// fail logs err, unless it's the same as the last one, and shows "(err)",
+// unless that's already showing.
+func (r *reporter) fail(err error) {
+	r.logErr(err)
 
-	for range debounce(updateCh, time.Second) {
-		out, err := exec.Command("acpi", "--battery").Output()
-		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
-			continue
-		}
+	if !r.failing {
+		r.failing = true
+		r.ch <- Segment{Text: "(err)"}
+	}
+}
 
-		if bytes.Equal(out, []byte("Battery 0: Full, 100%\n")) {
-			ch <- "charged 100%"
-			continue
-		}
+// stale logs err, unless it's the same as the last one, and shows s, an
+// earlier reading, in place of "(err)".
+func (r *reporter) stale(err error, s Segment) {
+	r.logErr(err)
+	r.failing = false
+	r.ch <- s
+}
 
-		m := re.FindSubmatch(out)
-		if m == nil {
-			log.Printf("acpi returned unexpected output: %q", out)
-			ch <- "(err)"
-			continue
-		}
+// A backoff spaces out retries of something that keeps failing, such as a
+// command run on a host that can't be reached, doubling the wait after each
+// failure from min up to max.
+type backoff struct {
+	min, max time.Duration
+	wait     time.Duration
+	until    time.Time
+}
 
-		status := string(m[1])
+// ready reports whether it's time to try again.
+func (b *backoff) ready(now time.Time) bool {
+	return !now.Before(b.until)
+}
 
-		percentage, err := strconv.Atoi(string(m[2]))
-		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
-			continue
-		}
+// failed lengthens the wait before the next try.
+func (b *backoff) failed(now time.Time) {
+	b.wait *= 2
+	if b.wait < b.min {
+		b.wait = b.min
+	} else if b.wait > b.max {
+		b.wait = b.max
+	}
+	b.until = now.Add(b.wait)
+}
 
-		var remaining time.Duration
+// succeeded makes the next try happen whenever it's due.
+func (b *backoff) succeeded() {
+	b.wait, b.until = 0, time.Time{}
+}
 
-		if m[3] != nil {
-			hours, err := strconv.Atoi(string(m[4]))
-			if err != nil {
-				log.Print(err)
-				ch <- "(err)"
-				continue
-			}
+// remoteCommand is like exec.CommandContext, but if config.Remote has a
+// prefix for loop, such as {"ssh", "server", "--"}, it runs the command
+// through that instead so it reads the other host.
+func remoteCommand(ctx context.Context, loop, name string, args ...string) *exec.Cmd {
+	prefix := config.Remote[loop]
+	if len(prefix) == 0 {
+		return exec.CommandContext(ctx, name, args...)
+	}
 
-			minutes, err := strconv.Atoi(string(m[5]))
-			if err != nil {
-				log.Print(err)
-				ch <- "(err)"
-				continue
-			}
+	full := append([]string(nil), prefix[1:]...)
+	full = append(full, name)
+	full = append(full, args...)
+	return exec.CommandContext(ctx, prefix[0], full...)
+}
 
-			seconds, err := strconv.Atoi(string(m[6]))
-			if err != nil {
-				log.Print(err)
-				ch <- "(err)"
-				continue
-			}
+// remoteOutput runs remoteCommand and returns its output.  A remote read is
+// killed after config.RemoteTimeout, so that a host that stops answering
+// shows up as a failure instead of holding the loop up for good.
+func remoteOutput(loop, name string, args ...string) ([]byte, error) {
+	ctx := context.Background()
+	if len(config.Remote[loop]) > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.RemoteTimeout)
+		defer cancel()
+	}
+	return remoteCommand(ctx, loop, name, args...).Output()
+}
 
-			remaining = time.Duration(hours)*time.Hour +
-				time.Duration(minutes)*time.Minute +
-				time.Duration(seconds)*time.Second
-		}
-
-		totalMinutes := int(remaining.Seconds() / 60)
-
-		switch status {
-		case "Charging":
-			remainingText := fmt.Sprintf("%dh%02dm", totalMinutes/60, totalMinutes%60)
-			ch <- fmt.Sprintf("charging %d%% (%s)", percentage, remainingText)
-		case "Discharging":
-			if percentage <= 20 {
-				remainingText := fmt.Sprintf("%dh%02dm", totalMinutes/60, totalMinutes%60)
-				ch <- fmt.Sprintf("\x04discharging %d%% (%s)", percentage, remainingText)
-			} else {
-				totalMinutes = int(float64(totalMinutes) * float64(percentage-20) / float64(percentage))
-				remainingText := fmt.Sprintf("%dh%02dm", totalMinutes/60, totalMinutes%60)
-				ch <- fmt.Sprintf("discharging %d%% (%s)", percentage, remainingText)
-			}
-		case "Unknown":
-			ch <- fmt.Sprintf("unknown %d%%", percentage)
-		}
+// failCached reports err like fail, except that it shows c's last segment
+// instead of "(err)" while that's recent enough.
+func (r *reporter) failCached(err error, c *cache) {
+	if s, ok := c.stale(); ok {
+		r.stale(err, s)
+		return
 	}
+	r.fail(err)
+}
+
+// A deadband filters out small changes in a noisy value, so a loop only
+// emits when the value has moved by more than width.  A zero width lets
+// every value through.
+type deadband struct {
+	width float64
+	last  float64
+	set   bool
+}
+
+// changed reports whether v differs from the last value the deadband accepted
+// by more than its width, and if so accepts v.  The first value after a reset
+// is always accepted.
+func (d *deadband) changed(v float64) bool {
+	if d.width > 0 && d.set && math.Abs(v-d.last) <= d.width {
+		return false
+	}
+	d.last, d.set = v, true
+	return true
+}
+
+// reset forgets the last accepted value, e.g. after the loop has emitted an
+// error in the meantime.
+func (d *deadband) reset() {
+	d.set = false
 }
 
-func timeLoop(ch chan<- string) {
+// An ema is an exponential moving average, used to smooth out jumpy samples.
+// Alpha is the weight each new sample gets; 0 or 1 follows the raw samples.
+type ema struct {
+	alpha float64
+	value float64
+	set   bool
+}
+
+// add folds x into the average and returns the new average.
+func (e *ema) add(x float64) float64 {
+	if !e.set || e.alpha <= 0 || e.alpha >= 1 {
+		e.value, e.set = x, true
+	} else {
+		e.value = e.alpha*x + (1-e.alpha)*e.value
+	}
+	return e.value
+}
+
+// A formats pairs the fmt format a loop normally renders its text with and a
+// shorter one used in compact mode.  Both are given the same arguments.
+type formats struct {
+	long, short string
+}
+
+// sprintf renders args with the format the current mode calls for.
+func (f formats) sprintf(args ...interface{}) string {
+	if config.Compact {
+		return fmt.Sprintf(f.short, args...)
+	}
+	return fmt.Sprintf(f.long, args...)
+}
+
+func timeLoop(ch chan<- Segment) {
 	const format = "Mon 2 Jan 2006 3:04 pm -0700 MST"
 
-	ch <- time.Now().Format(format)
+	ch <- Segment{Text: time.Now().Format(format)}
 	now := time.Now()
 	start := now.Round(time.Minute)
 	if start.Before(now) {
@@ -214,115 +357,151 @@ func timeLoop(ch chan<- string) {
 	}
 	time.Sleep(start.Sub(now))
 
-	for now := range eagerTick(time.Minute) {
-		ch <- now.Format(format)
+	for now := range eagerTick(context.Background(), time.Minute) {
+		// Switch profiles before sending, so the render this triggers
+		// already uses the new profile.
+		applySchedule(now)
+		ch <- Segment{Text: now.Format(format)}
 	}
 }
 
-func memoryLoop(ch chan<- string) {
-	re := regexp.MustCompile(`(.*): +(\d+) kB`)
-	for range eagerTick(time.Second) {
-		data, err := ioutil.ReadFile("/proc/meminfo")
+var meminfoRe = regexp.MustCompile(`(.*): +(\d+) kB`)
+
+// readMeminfo returns the fields of /proc/meminfo, in kB.
+func readMeminfo() (map[string]float64, error) {
+	data, err := ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]float64)
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		m := meminfoRe.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		name := string(m[1])
+		kB, err := strconv.Atoi(string(m[2]))
 		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
+			continue
 		}
 
-		var total, available float64
+		fields[name] = float64(kB)
+	}
 
-		for _, line := range bytes.Split(data, []byte("\n")) {
-			m := re.FindSubmatch(line)
-			if m == nil {
-				continue
-			}
+	return fields, nil
+}
 
-			name := string(m[1])
-			kB, err := strconv.Atoi(string(m[2]))
-			if err != nil {
-				continue
-			}
+func memoryLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
 
-			MB := float64(kB) / 1000
+	used := deadband{width: config.Memory.Deadband}
 
-			if name == "MemTotal" {
-				total = MB
-			} else if name == "MemAvailable" {
-				available = MB
-			}
+	for range eagerTick(context.Background(), time.Second) {
+		fields, err := readMeminfo()
+		if err != nil {
+			r.fail(err)
+			used.reset()
+			continue
 		}
 
-		ch <- fmt.Sprintf("RAM: %.0f%%", 100*float64(total-available)/float64(total))
+		total := fields["MemTotal"]
+		if total == 0 {
+			r.fail(errors.New("/proc/meminfo has no MemTotal"))
+			used.reset()
+			continue
+		}
+
+		available, ok := fields["MemAvailable"]
+		if !ok {
+			// Kernels before 3.14 don't report MemAvailable, so estimate it
+			// the way free(1) used to.
+			available = fields["MemFree"] + fields["Buffers"] + fields["Cached"]
+		}
+
+		percentage := 100 * float64(total-available) / float64(total)
+		if used.changed(percentage) {
+			r.send(fmt.Sprintf("%.0f%%", percentage))
+		}
 	}
 }
 
-func brightnessLoop(ch chan<- string) {
+// perceivedBrightness maps a percentage of the backlight's maximum to roughly
+// how bright it looks, by undoing a gamma curve: with gamma 2.2 a backlight at
+// 25% reads as 53%.  A gamma of 0 or 1 leaves the percentage as it is.
+func perceivedBrightness(percentage, gamma float64) float64 {
+	if gamma <= 0 || gamma == 1 || percentage <= 0 {
+		return percentage
+	}
+	return 100 * math.Pow(percentage/100, 1/gamma)
+}
+
+func brightnessLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Print(err)
-		ch <- "(err)"
+		r.fail(err)
 		return
 	}
 	defer watcher.Close()
 
 	if err := watcher.Add("/sys/class/backlight/intel_backlight/brightness"); err != nil {
-		log.Print(err)
-		ch <- "(err)"
+		r.fail(err)
 		return
 	}
 
 	update := func() {
 		out, err := exec.Command("xbacklight").Output()
 		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
+			r.fail(err)
 			return
 		}
 
 		percentage, err := strconv.ParseFloat(string(bytes.TrimSpace(out)), 64)
 		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
+			r.fail(err)
 			return
 		}
+		percentage = perceivedBrightness(percentage, config.Brightness.Gamma)
 
-		ch <- fmt.Sprintf("\u2600%.0f%%", percentage)
+		segment := Segment{Text: fmt.Sprintf("%.0f%%", percentage)}
+		if limit := currentProfile().BrightnessWarning; limit > 0 && percentage > limit {
+			segment.Level = LevelWarning
+		}
+		r.sendSegment(segment)
 	}
 
 	update()
 
-	for {
-		select {
-		case _, ok := <-watcher.Events:
-			if !ok {
-				ch <- "(err)"
-				return
-			}
-			update()
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				log.Print("watcher errors chan closed")
-			} else {
-				log.Print(err)
-			}
-		}
+	// Holding a brightness key fires a burst of events, so coalesce them and
+	// only read the brightness once things settle down.
+	for range debounce(watchEvents(watcher, nil), config.Brightness.Debounce) {
+		update()
 	}
+	r.fail(errors.New("brightness watcher stopped"))
 }
 
-func networkLoop(ch chan<- string) {
+func networkLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
 	cmd := exec.Command("ifstat", "-T", "0.1/3")
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Print(err)
-		ch <- "(err)"
+		r.fail(err)
 		return
 	}
 	defer stdout.Close()
-	if err := cmd.Start(); err != nil {
-		log.Print(err)
-		ch <- "(err)"
+	if err := startChild(cmd); err != nil {
+		r.fail(err)
 		return
 	}
 
+	downAvg := ema{alpha: config.Network.Smoothing}
+	upAvg := ema{alpha: config.Network.Smoothing}
+
 	scanner := bufio.NewScanner(stdout)
 	scanner.Scan()
 	scanner.Scan()
@@ -331,19 +510,27 @@ func networkLoop(ch chan<- string) {
 		fields := strings.Fields(line)
 		down, err := strconv.ParseFloat(fields[len(fields)-2], 64)
 		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
+			r.fail(err)
 			continue
 		}
 
 		up, err := strconv.ParseFloat(fields[len(fields)-1], 64)
 		if err != nil {
-			log.Print(err)
-			ch <- "(err)"
+			r.fail(err)
 			continue
 		}
 
-		ch <- fmt.Sprintf("\u25be%.1f \u25b4%.1f", down, up)
+		down, up = downAvg.add(down), upAvg.add(up)
+
+		if limit := config.Network.IdleBelow; limit > 0 && down < limit && up < limit {
+			r.send(config.Network.IdleText)
+			continue
+		}
+
+		r.send(fmt.Sprintf("\u25be%.1f \u25b4%.1f", down, up))
+	}
+	if err := waitChild(cmd); err != nil {
+		log.Printf("ifstat: %s", err)
 	}
 }
 
@@ -382,58 +569,524 @@ func killOthers() {
 	}
 }
 
+// formatDuration renders d, to the minute, in one of the styles
+// config.DurationStyle can name: "compact" (1h05m), "clock" (1:05) or
+// "spaced" (1h 5m, or just 5m under an hour).  Hours aren't rolled over into
+// days, so a day and an hour is 25h00m.  Negative durations show as zero.
+func formatDuration(d time.Duration, style string) string {
+	minutes := int(d / time.Minute)
+	if minutes < 0 {
+		minutes = 0
+	}
+	hours, minutes := minutes/60, minutes%60
+
+	switch style {
+	case "clock":
+		return fmt.Sprintf("%d:%02d", hours, minutes)
+	case "spaced":
+		if hours == 0 {
+			return fmt.Sprintf("%dm", minutes)
+		}
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dh%02dm", hours, minutes)
+	}
+}
+
+// megabytes converts a number of bytes to megabytes, either SI (MB, 1000²
+// bytes) or binary (MiB, 1024² bytes) according to config.BinaryUnits, and
+// returns the unit's name alongside.
+func megabytes(bytes float64) (float64, string) {
+	if config.BinaryUnits {
+		return bytes / (1024 * 1024), "MiB"
+	}
+	return bytes / (1000 * 1000), "MB"
+}
+
+// loops maps the names used in config.Segments to the functions that produce
+// each segment.
+var loops = map[string]func(chan<- Segment){
+	"power":      powerLoop,
+	"brightness": brightnessLoop,
+	"network":    networkLoop,
+	"diskio":     diskioLoop,
+	"thermal":    thermalLoop,
+	"memory":     memoryLoop,
+	"time":       timeLoop,
+	"display":    displayLoop,
+	"swap":       swapLoop,
+	"mic":        micLoop,
+	"updates":    updatesLoop,
+	"title":      titleLoop,
+	"disk":       diskLoop,
+	"services":   servicesLoop,
+	"proc":       procLoop,
+	"idle":       idleLoop,
+	"git":        gitLoop,
+}
+
+// Color escapes understood by dwm's statuscolors patch.  Each selects the
+// color scheme used for the text that follows it.
+const (
+	colorNormal   = "\x01"
+	colorWarning  = "\x03"
+	colorCritical = "\x04"
+)
+
+// colorNames lets config refer to color escapes by name.
+var colorNames = map[string]string{
+	"normal":   colorNormal,
+	"warning":  colorWarning,
+	"critical": colorCritical,
+}
+
+// colorEscape resolves color, which is either one of colorNames or a raw
+// escape byte, to its escape.  It reports false for anything else.
+func colorEscape(color string) (string, bool) {
+	if escape, ok := colorNames[color]; ok {
+		return escape, true
+	}
+	if len(color) == 1 && color[0] < ' ' {
+		return color, true
+	}
+	return "", false
+}
+
+// colorize puts text in the given color.  Escapes within text still take
+// effect after the first, so loops that color their own text win out.
+func colorize(color, text string) string {
+	escape, _ := colorEscape(color)
+	return escape + text
+}
+
+// visibleLen returns the number of runes in s that dwm will actually draw,
+// i.e. not counting the control bytes used as color escapes.
+func visibleLen(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= ' ' {
+			n++
+		}
+	}
+	return n
+}
+
+// splitEscape splits the color escape, if any, off the front of text.
+func splitEscape(text string) (escape, rest string) {
+	if len(text) > 0 && text[0] < ' ' {
+		return text[:1], text[1:]
+	}
+	return "", text
+}
+
+// pad pads text with spaces to at least width visible runes, keeping any
+// leading color escape in front.  A positive width right-aligns the text and a
+// negative one left-aligns it.
+func pad(text string, width int) string {
+	left := width > 0
+	if width < 0 {
+		width = -width
+	}
+
+	n := width - visibleLen(text)
+	if n <= 0 {
+		return text
+	}
+
+	escape, text := splitEscape(text)
+
+	if left {
+		return escape + strings.Repeat(" ", n) + text
+	}
+	return escape + text + strings.Repeat(" ", n)
+}
+
+// label puts the configured icon or label for a loop in front of its text,
+// after any leading color escape so it's drawn in the same color.  Icons win
+// over labels, and compact mode leaves labels out.
+func label(loop, text string) string {
+	prefix := ""
+	if icon := config.Icons[loop]; icon != "" {
+		prefix = icon + " "
+	} else if !config.Compact {
+		prefix = config.Labels[loop]
+	}
+
+	escape, text := splitEscape(text)
+	return escape + prefix + text
+}
+
+// truncate cuts s down to at most n visible runes, ending it with an ellipsis
+// if anything was cut.
+func truncate(s string, n int) string {
+	if visibleLen(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+
+	seen := 0
+	for i, r := range s {
+		if r < ' ' {
+			continue
+		}
+		if seen == n-1 {
+			return s[:i] + "\u2026"
+		}
+		seen++
+	}
+	return s
+}
+
+// dropSegments removes segments, lowest config priority first, until the
+// rest fit in max visible runes or only one is left.  Ties are broken by
+// dropping the leftmost segment first.
+func dropSegments(segments []string, priorities []int, max int) []string {
+	segments = append([]string(nil), segments...)
+	priorities = append([]int(nil), priorities...)
+
+	for len(segments) > 1 && visibleLen(strings.Join(segments, separator)) > max {
+		lowest := 0
+		for i, priority := range priorities {
+			if priority < priorities[lowest] {
+				lowest = i
+			}
+		}
+		segments = append(segments[:lowest], segments[lowest+1:]...)
+		priorities = append(priorities[:lowest], priorities[lowest+1:]...)
+	}
+
+	return segments
+}
+
+// separator goes between segments, resetting the color first so it isn't
+// drawn in whatever color the segment before it used.
+const separator = colorNormal + " | "
+
+// blinking reports whether any segment that is set to blink is currently
+// critical.
+func blinking(chunks []Segment) bool {
+	for i, chunk := range chunks {
+		if config.Segments[i].Blink && chunk.critical() {
+			return true
+		}
+	}
+	return false
+}
+
+// render joins the latest segment from each loop into the full status text,
+// shortened to config.MaxLength if that is set.  Segments whose loop has
+// nothing to show are left out altogether.  If dim is true, blinking
+// segments are drawn in config.BlinkColor instead of as critical.
+func render(chunks []Segment, dim bool) string {
+	var segments []string
+	var priorities []int
+	for i, chunk := range chunks {
+		if chunk.Text == "" {
+			continue
+		}
+		segment := config.Segments[i]
+		text := chunk.Level.escape() + chunk.Text
+		if dim && segment.Blink {
+			escape, _ := colorEscape(config.BlinkColor)
+			text = strings.Replace(text, colorCritical, escape, -1)
+		}
+		text = pad(label(segment.Loop, text), segment.Width)
+		segments = append(segments, colorize(segment.Color, text))
+
+		priority := segment.Priority
+		if priority == 0 {
+			priority = chunk.Priority
+		}
+		priorities = append(priorities, priority)
+	}
+
+	if config.MaxLength > 0 && config.Truncate == "drop" {
+		segments = dropSegments(segments, priorities, config.MaxLength)
+	}
+
+	text := currentProfile().recolor(strings.Join(segments, separator))
+	if config.MaxLength > 0 {
+		text = truncate(text, config.MaxLength)
+	}
+	return text
+}
+
+type update struct {
+	index   int
+	segment Segment
+}
+
+// waitForAll applies updates to chunks until every segment has reported at
+// least once or timeout elapses, whichever comes first.  Segments that
+// haven't reported by then keep their placeholder.
+func waitForAll(updateCh <-chan update, chunks []Segment, updated []time.Time, timeout time.Duration) {
+	remaining := len(chunks)
+	deadline := time.After(timeout)
+
+	for remaining > 0 {
+		select {
+		case update := <-updateCh:
+			if updated[update.index].IsZero() {
+				remaining--
+			}
+			chunks[update.index] = update.segment
+			updated[update.index] = time.Now()
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// logHealth logs each segment's loop, latest text, and how long ago it was
+// updated.
+func logHealth(chunks []Segment, updated []time.Time) {
+	for i, segment := range config.Segments {
+		if updated[i].IsZero() {
+			log.Printf("%s: %q, never updated", segment.Loop, chunks[i].Text)
+		} else {
+			log.Printf("%s: %q, updated %s ago", segment.Loop, chunks[i].Text, time.Since(updated[i]).Round(time.Second))
+		}
+	}
+}
+
 func main() {
-	killOthers()
+	oneshot := flag.Bool("oneshot", false, "print the status to stdout once and exit")
+	timeout := flag.Duration("timeout", 5*time.Second, "with -oneshot, how long to wait for every segment to report")
+	stdin := flag.Bool("stdin", false, "read commands such as \"refresh power\" from stdin, one per line")
+	debug := flag.Bool("debug", false, "log every segment update and whether it changed the status")
+	configPath := flag.String("config", defaultConfigPath(), "the config `file`, which is reloaded whenever it changes")
+	check := flag.Bool("check", false, "check the config and exit")
+	showVersion := flag.Bool("version", false, "print version and build information and exit")
+	flag.Parse()
+
+	// Debouncing and intervals are tuned in milliseconds, so show them.
+	if *debug {
+		log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	}
 
-	log.Printf("Starting")
+	if *showVersion {
+		fmt.Printf("dwmstatus %s (commit %s, built %s)\n", version, commit, date)
+		return
+	}
+
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatal(err)
+	}
+
+	sinks, err := newSinks()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Truncate != "ellipsis" && config.Truncate != "drop" {
+		log.Fatalf("Unknown truncation mode %q", config.Truncate)
+	}
+	switch config.DurationStyle {
+	case "compact", "clock", "spaced":
+	default:
+		log.Fatalf("Unknown duration style %q", config.DurationStyle)
+	}
+	// A zero or negative interval would make the loop's ticker panic as soon
+	// as it starts.
+	for _, interval := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"BlinkInterval", config.BlinkInterval},
+		{"DiskIO.Interval", config.DiskIO.Interval},
+		{"Display.Interval", config.Display.Interval},
+		{"Swap.Interval", config.Swap.Interval},
+		{"Mic.Interval", config.Mic.Interval},
+		{"Updates.Interval", config.Updates.Interval},
+		{"Disk.Interval", config.Disk.Interval},
+		{"Services.Interval", config.Services.Interval},
+		{"Proc.Interval", config.Proc.Interval},
+		{"Idle.Interval", config.Idle.Interval},
+	} {
+		if interval.d <= 0 {
+			log.Fatalf("%s must be positive, not %s", interval.name, interval.d)
+		}
+	}
+	if len(config.Updates.Command) == 0 {
+		log.Fatal("Updates.Command is empty")
+	}
+	for loop := range config.Remote {
+		if loop != "thermal" {
+			log.Fatalf("The %s loop can't read a remote host", loop)
+		}
+	}
+	if config.RemoteTimeout <= 0 {
+		log.Fatalf("RemoteTimeout must be positive, not %s", config.RemoteTimeout)
+	}
+	if !containsString([]string{"acpi", "sysfs"}, config.Power.Source) {
+		log.Fatalf("Unknown power source %q", config.Power.Source)
+	}
+	if !containsString([]string{"acpi", "zones", "sensors"}, config.Thermal.Source) {
+		log.Fatalf("Unknown thermal source %q", config.Thermal.Source)
+	}
+	if !containsString([]string{"loadavg", "count"}, config.Proc.Source) {
+		log.Fatalf("Unknown proc source %q", config.Proc.Source)
+	}
+	if err := checkProfiles(); err != nil {
+		log.Fatal(err)
+	}
+	applySchedule(time.Now())
+
+	loopFuncs := make([]func(chan<- Segment), len(config.Segments))
+	for i, segment := range config.Segments {
+		f, ok := lookupLoop(segment.Loop)
+		if !ok {
+			log.Fatalf("Unknown loop %q", segment.Loop)
+		}
+		if _, ok := colorEscape(segment.Color); segment.Color != "" && !ok {
+			log.Fatalf("Unknown color %q for %s", segment.Color, segment.Loop)
+		}
+		if _, ok := colorEscape(config.BlinkColor); segment.Blink && !ok {
+			log.Fatalf("Unknown blink color %q", config.BlinkColor)
+		}
+		loopFuncs[i] = f
+	}
+	if _, ok := colorEscape(config.StaleColor); config.StaleColor != "" && !ok {
+		log.Fatalf("Unknown stale color %q", config.StaleColor)
+	}
 
-	loopFuncs := []func(chan<- string){
-		powerLoop,
-		brightnessLoop,
-		networkLoop,
-		thermalLoop,
-		memoryLoop,
-		timeLoop,
+	if *check {
+		return
 	}
 
-	type update struct {
-		index int
-		text  string
+	// A one-off run shouldn't take down the instance running the bar.
+	if !*oneshot {
+		killOthers()
 	}
 
+	log.Printf("Starting")
+
+	// kill -USR1 switches thermal between degrees F and C.  It's caught here,
+	// before any loop starts, so that the signal can't kill dwmstatus while
+	// there's no thermal loop listening.
+	toggleCh := make(chan os.Signal, 1)
+	signal.Notify(toggleCh, syscall.SIGUSR1)
+	go func() {
+		for range toggleCh {
+			dispatch(command{verb: "toggle", loop: "thermal"})
+		}
+	}()
+
 	updateCh := make(chan update)
 
 	for i, f := range loopFuncs {
-		i := i
-		ch := make(chan string)
-		go f(ch)
+		i, f := i, f
+		ch := make(chan Segment)
+		go func() {
+			time.Sleep(config.Segments[i].Delay)
+			f(ch)
+		}()
 		go func() {
 			for s := range ch {
 				updateCh <- update{
-					index: i,
-					text:  s,
+					index:   i,
+					segment: s,
 				}
 			}
 		}()
 	}
 
-	chunks := make([]string, len(loopFuncs))
+	chunks := make([]Segment, len(loopFuncs))
 	for i := range chunks {
-		chunks[i] = "..."
+		chunks[i] = Segment{Text: "..."}
 	}
 
+	updated := make([]time.Time, len(loopFuncs))
+
+	if *oneshot {
+		waitForAll(updateCh, chunks, updated, *timeout)
+		fmt.Println(render(chunks, false))
+		return
+	}
+
+	if *stdin {
+		go readCommands(os.Stdin)
+	}
+
+	if *configPath != "" {
+		go watchConfig(*configPath)
+	}
+
+	// kill -USR2 dumps what each loop last reported, and when, to help find
+	// which one is stuck.
+	healthCh := make(chan os.Signal, 1)
+	signal.Notify(healthCh, syscall.SIGUSR2)
+
+	// While a blinking segment is critical, blinker flips it between its
+	// critical color and a dim one.
+	var blinker *time.Ticker
+	var blinkCh <-chan time.Time
+	dim := false
+
+	sinks = runnableSinks(sinks)
+
 	oldText := ""
 
-	for update := range updateCh {
-		chunks[update.index] = update.text
+	show := func() {
+		newText := render(chunks, dim)
+
+		if newText == oldText {
+			if *debug {
+				log.Print("Status unchanged, not updating")
+			}
+			return
+		}
+		oldText = newText
+
+		if *debug {
+			log.Printf("Setting status to %q", newText)
+		}
+
+		for _, sink := range sinks {
+			if err := sink.Show(newText); err != nil {
+				log.Printf("%s: %s", sink, err)
+			}
+		}
+	}
 
-		newText := strings.Join(chunks, "\x01 | ")
+	// startBlinking starts or stops the blinker according to whether any
+	// blinking segment is critical.
+	startBlinking := func() {
+		critical := blinking(chunks)
+		if critical && blinker == nil {
+			blinker = time.NewTicker(config.BlinkInterval)
+			blinkCh = blinker.C
+		} else if !critical && blinker != nil {
+			blinker.Stop()
+			blinker, blinkCh, dim = nil, nil, false
+		}
+	}
 
-		if newText != oldText {
-			if err := exec.Command("xsetroot", "-name", newText).Run(); err != nil {
-				log.Printf("xsetroot: %s", err)
+	// Rather than fill the bar in piecemeal, optionally hold off on showing
+	// it until every segment has something to show.
+	if config.WaitForAll > 0 {
+		waitForAll(updateCh, chunks, updated, config.WaitForAll)
+		startBlinking()
+		show()
+	}
+
+	for {
+		select {
+		case update := <-updateCh:
+			if *debug {
+				log.Printf("Segment %d (%s): %q, level %d", update.index, config.Segments[update.index].Loop, update.segment.Text, update.segment.Level)
 			}
-			oldText = newText
+			chunks[update.index] = update.segment
+			updated[update.index] = time.Now()
+
+			startBlinking()
+			show()
+		case <-blinkCh:
+			dim = !dim
+			show()
+		case <-healthCh:
+			logHealth(chunks, updated)
 		}
 	}
 }
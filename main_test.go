@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEagerTickDropsTicksForSlowReceiver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := eagerTick(ctx, time.Millisecond)
+
+	// Many ticks go by without anyone receiving.
+	time.Sleep(50 * time.Millisecond)
+
+	if n := len(ticks); n != 1 {
+		t.Errorf("%d ticks pending, want 1", n)
+	}
+
+	// A ticker blocked on sending couldn't notice ctx and close the channel.
+	cancel()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ticks:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("eagerTick didn't stop after ctx was done")
+		}
+	}
+}
+
+func TestDebounceDoesNotBlockSenderOnSlowReceiver(t *testing.T) {
+	ch := make(chan time.Time)
+	out := debounce(ch, time.Millisecond)
+
+	// Several bursts settle while nobody receives from out.  Each send has
+	// to go through promptly regardless.
+	for burst := 0; burst < 5; burst++ {
+		for i := 0; i < 10; i++ {
+			select {
+			case ch <- time.Now():
+			case <-time.After(time.Second):
+				t.Fatalf("sending burst %d blocked", burst)
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := len(out); n != 1 {
+		t.Errorf("%d values pending, want 1", n)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d       time.Duration
+		compact string
+		clock   string
+		spaced  string
+	}{
+		{0, "0h00m", "0:00", "0m"},
+		{59 * time.Second, "0h00m", "0:00", "0m"},
+		{time.Hour, "1h00m", "1:00", "1h 0m"},
+		{65 * time.Minute, "1h05m", "1:05", "1h 5m"},
+		{25 * time.Hour, "25h00m", "25:00", "25h 0m"},
+		{-time.Hour, "0h00m", "0:00", "0m"},
+	}
+
+	for _, test := range tests {
+		for style, want := range map[string]string{
+			"compact": test.compact,
+			"clock":   test.clock,
+			"spaced":  test.spaced,
+		} {
+			if got := formatDuration(test.d, style); got != want {
+				t.Errorf("formatDuration(%s, %q) = %q, want %q", test.d, style, got, want)
+			}
+		}
+	}
+}
+
+func TestWatchEventsFiltersAndCloses(t *testing.T) {
+	dir := t.TempDir()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	events := watchEvents(watcher, func(name string) bool {
+		return filepath.Base(name) == "wanted"
+	})
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-events:
+		t.Error("got an event for a file that doesn't match")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "wanted"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Error("got no event for the matching file")
+	}
+
+	// Drop any further events for the write, then make sure closing the
+	// watcher closes the channel, and with it debounce's.
+	out := debounce(events, time.Millisecond)
+	watcher.Close()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("debounce didn't close after the watcher did")
+		}
+	}
+}
+
+func TestCacheStaleKeepsEmptySegmentEmpty(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+	config.StaleColor = "warning"
+
+	c := cache{maxAge: time.Hour}
+	c.store(Segment{})
+	if s, ok := c.stale(); !ok || s.Text != "" {
+		t.Errorf("stale() = %+v, %v; want an empty segment", s, ok)
+	}
+
+	c.store(Segment{Text: "12"})
+	if s, ok := c.stale(); !ok || s.Text != colorWarning+"12" {
+		t.Errorf("stale() = %+v, %v; want 12 in the warning color", s, ok)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// micActive reports whether any ALSA capture stream is running.  This covers
+// PulseAudio and PipeWire too, since they capture through ALSA.
+func micActive() (bool, error) {
+	paths, err := filepath.Glob("/proc/asound/card*/pcm*c/sub*/status")
+	if err != nil {
+		return false, err
+	}
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Contains(data, []byte("state: RUNNING")) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func micLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	updateCh := make(chan time.Time)
+
+	// If PulseAudio is around it tells us as soon as a recording starts or
+	// stops; otherwise we rely on polling alone.
+	if _, err := exec.LookPath("pactl"); err == nil {
+		go func() {
+			cmd := exec.Command("pactl", "subscribe")
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				log.Print(err)
+				return
+			}
+			defer stdout.Close()
+			if err := startChild(cmd); err != nil {
+				log.Print(err)
+				return
+			}
+
+			scanner := bufio.NewScanner(stdout)
+			for scanner.Scan() {
+				if bytes.Contains(scanner.Bytes(), []byte("source-output")) {
+					updateCh <- time.Now()
+				}
+			}
+			if err := waitChild(cmd); err != nil {
+				log.Printf("pactl: %s", err)
+			}
+		}()
+	}
+
+	go func() {
+		for t := range eagerTick(context.Background(), config.Mic.Interval) {
+			updateCh <- t
+		}
+	}()
+
+	for range updateCh {
+		active, err := micActive()
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		if active {
+			r.send(config.Mic.Glyph)
+		} else {
+			r.send("")
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// A battery is a reading of the battery's state, independent of where it
+// came from.
+type battery struct {
+	// status is one of "Charging", "Discharging", "Full", "Not charging"
+	// or "Unknown", as reported by both acpi and sysfs.
+	status     string
+	percentage int
+
+	// remaining is how long until the battery is empty or full, if
+	// estimated is true.
+	remaining time.Duration
+	estimated bool
+}
+
+var acpiBatteryRe = regexp.MustCompile(`Battery \d+: (Unknown|Charging|Discharging|Full|Not charging), (\d+)%(, (\d+):(\d+):(\d+))?`)
+
+// acpiBattery reads the first battery acpi reports.
+func acpiBattery() (battery, error) {
+	out, err := exec.Command("acpi", "--battery").Output()
+	if err != nil {
+		return battery{}, err
+	}
+
+	return parseAcpiBattery(out)
+}
+
+// parseAcpiBattery parses the first battery in the output of acpi --battery.
+func parseAcpiBattery(out []byte) (battery, error) {
+	var err error
+
+	m := acpiBatteryRe.FindSubmatch(out)
+	if m == nil {
+		return battery{}, fmt.Errorf("acpi returned unexpected output: %q", out)
+	}
+
+	b := battery{status: string(m[1])}
+
+	b.percentage, err = strconv.Atoi(string(m[2]))
+	if err != nil {
+		return battery{}, err
+	}
+
+	if m[3] != nil {
+		hours, err := strconv.Atoi(string(m[4]))
+		if err != nil {
+			return battery{}, err
+		}
+
+		minutes, err := strconv.Atoi(string(m[5]))
+		if err != nil {
+			return battery{}, err
+		}
+
+		seconds, err := strconv.Atoi(string(m[6]))
+		if err != nil {
+			return battery{}, err
+		}
+
+		b.remaining = time.Duration(hours)*time.Hour +
+			time.Duration(minutes)*time.Minute +
+			time.Duration(seconds)*time.Second
+		b.estimated = true
+	}
+
+	return b, nil
+}
+
+// findBatteries returns the directories under root, normally
+// /sys/class/power_supply, of the supplies whose type is Battery.  Their names
+// vary between machines (BAT0, BAT1, CMB0, ...), so they're found by type
+// rather than by name.
+func findBatteries(root string) ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var batteries []string
+	for _, dir := range dirs {
+		if supplyType, err := readSysString(filepath.Join(dir, "type")); err == nil && supplyType == "Battery" {
+			batteries = append(batteries, dir)
+		}
+	}
+
+	return batteries, nil
+}
+
+// batteryEnergy returns how much energy the battery in dir holds, how much it
+// holds when full, and how fast it is charging or discharging.  Depending on
+// the driver these are in µWh and µW or in µAh and µA.
+func batteryEnergy(dir string) (now, full, rate float64, err error) {
+	for _, names := range [][3]string{
+		{"energy_now", "energy_full", "power_now"},
+		{"charge_now", "charge_full", "current_now"},
+	} {
+		now, err = readSysFloat(filepath.Join(dir, names[0]))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, 0, 0, err
+		}
+
+		full, err = readSysFloat(filepath.Join(dir, names[1]))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		// Not every driver knows the rate; without it there's just no
+		// estimate.
+		rate, _ = readSysFloat(filepath.Join(dir, names[2]))
+
+		return now, full, rate, nil
+	}
+
+	return 0, 0, 0, fmt.Errorf("%s reports neither energy nor charge", dir)
+}
+
+// sysfsBattery reads the batteries under root, normally
+// /sys/class/power_supply, combining them as if they were one big battery.
+// This assumes all of them report in the same units, which is the case for
+// batteries handled by the same driver.
+func sysfsBattery(root string) (battery, error) {
+	dirs, err := findBatteries(root)
+	if err != nil {
+		return battery{}, err
+	}
+	if len(dirs) == 0 {
+		return battery{}, fmt.Errorf("no batteries in %s", root)
+	}
+
+	var now, full, rate float64
+	statuses := make(map[string]int)
+
+	for _, dir := range dirs {
+		status, err := readSysString(filepath.Join(dir, "status"))
+		if err != nil {
+			return battery{}, err
+		}
+		statuses[status]++
+
+		n, f, r, err := batteryEnergy(dir)
+		if err != nil {
+			return battery{}, err
+		}
+		now += n
+		full += f
+		rate += r
+	}
+
+	if full <= 0 {
+		return battery{}, errors.New("batteries report zero capacity")
+	}
+
+	b := battery{percentage: int(100*now/full + 0.5)}
+
+	// One battery charging or discharging while another sits full still
+	// means the pack as a whole is charging or discharging.
+	switch {
+	case statuses["Discharging"] > 0:
+		b.status = "Discharging"
+		if rate > 0 {
+			b.remaining = time.Duration(now / rate * float64(time.Hour))
+			b.estimated = true
+		}
+	case statuses["Charging"] > 0:
+		b.status = "Charging"
+		if rate > 0 {
+			b.remaining = time.Duration((full - now) / rate * float64(time.Hour))
+			b.estimated = true
+		}
+	case statuses["Not charging"] > 0:
+		b.status = "Not charging"
+	case statuses["Full"] == len(dirs):
+		b.status = "Full"
+	default:
+		b.status = "Unknown"
+	}
+
+	return b, nil
+}
+
+// batteryGlyph returns the glyph from config.Power.Glyphs, which run from
+// empty to full, that matches percentage, followed by config.Power.ChargingGlyph
+// if the battery is charging.  It returns "" if icons are turned off or the
+// glyph set is empty, in which case powerLoop falls back to text.
+func batteryGlyph(percentage int, charging bool) string {
+	glyphs := config.Power.Glyphs
+	if !config.Power.Icons || len(glyphs) == 0 {
+		return ""
+	}
+
+	i := percentage * len(glyphs) / 101
+	if i < 0 {
+		i = 0
+	} else if i >= len(glyphs) {
+		i = len(glyphs) - 1
+	}
+
+	if charging {
+		return glyphs[i] + config.Power.ChargingGlyph
+	}
+	return glyphs[i]
+}
+
+// remainingText describes how long until the battery is empty or full, given
+// an estimate in minutes.  With config.Power.ShowUntil it gives the wall
+// clock time that will happen instead, unless there is no estimate.
+func remainingText(minutes int, estimated bool) string {
+	if config.Power.ShowUntil && estimated {
+		until := time.Now().Add(time.Duration(minutes) * time.Minute)
+		return "until " + until.Format("15:04")
+	}
+	return formatDuration(time.Duration(minutes)*time.Minute, config.DurationStyle)
+}
+
+// formatBattery renders b as powerLoop's segment, critical while discharging
+// at 20% or less.
+func formatBattery(b battery) Segment {
+	var (
+		idleFormats        = formats{"%s %d%%", "%[2]d%%"}
+		heldFormats        = formats{"%s %d%%", "=%[2]d%%"}
+		chargingFormats    = formats{"charging %d%% (%s)", "\u2191%d%% %s"}
+		dischargingFormats = formats{"discharging %d%% (%s)", "\u2193%d%% %s"}
+		chargingNoTime     = formats{"charging %d%%", "\u2191%d%%"}
+		dischargingNoTime  = formats{"discharging %d%%", "\u2193%d%%"}
+		unknownFormats     = formats{"unknown %d%%", "?%d%%"}
+		iconFormats        = formats{"%s %d%% (%s)", "%s%d%% %s"}
+		iconIdleFormats    = formats{"%s %d%%", "%s%d%%"}
+	)
+
+	percentage := b.percentage
+	totalMinutes := int(b.remaining.Seconds() / 60)
+	glyph := batteryGlyph(percentage, b.status == "Charging")
+
+	switch b.status {
+	case "Full", "Not charging":
+		// "Not charging" means the battery is plugged in but being held
+		// below full, e.g. by a ThinkPad charge threshold.
+		held := b.status == "Not charging"
+
+		if glyph != "" {
+			if held {
+				glyph += config.Power.HeldGlyph
+			}
+			return Segment{Text: iconIdleFormats.sprintf(glyph, percentage)}
+		} else if held {
+			return Segment{Text: heldFormats.sprintf(config.Power.NotCharging, percentage)}
+		}
+		return Segment{Text: idleFormats.sprintf(config.Power.Full, percentage)}
+	case "Charging":
+		if config.Power.HideChargingTime {
+			if glyph != "" {
+				return Segment{Text: iconIdleFormats.sprintf(glyph, percentage)}
+			}
+			return Segment{Text: chargingNoTime.sprintf(percentage)}
+		}
+
+		left := remainingText(totalMinutes, b.estimated)
+		if glyph != "" {
+			return Segment{Text: iconFormats.sprintf(glyph, percentage, left)}
+		}
+		return Segment{Text: chargingFormats.sprintf(percentage, left)}
+	case "Discharging":
+		level := LevelNormal
+		if percentage <= 20 {
+			level = LevelCritical
+		} else {
+			totalMinutes = int(float64(totalMinutes) * float64(percentage-20) / float64(percentage))
+		}
+		if config.Power.HideDischargingTime {
+			if glyph != "" {
+				return Segment{Text: iconIdleFormats.sprintf(glyph, percentage), Level: level}
+			}
+			return Segment{Text: dischargingNoTime.sprintf(percentage), Level: level}
+		}
+
+		left := remainingText(totalMinutes, b.estimated)
+		if glyph != "" {
+			return Segment{Text: iconFormats.sprintf(glyph, percentage, left), Level: level}
+		}
+		return Segment{Text: dischargingFormats.sprintf(percentage, left), Level: level}
+	default:
+		if glyph != "" {
+			return Segment{Text: iconIdleFormats.sprintf(glyph, percentage)}
+		}
+		return Segment{Text: unknownFormats.sprintf(percentage)}
+	}
+}
+
+func powerLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	updateCh := make(chan time.Time)
+
+	go func() {
+		// Whenever upower --monitor detects a change, we'll want to update the
+		// power text.
+		cmd := exec.Command("upower", "--monitor")
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		defer stdout.Close()
+		if err := startChild(cmd); err != nil {
+			log.Print(err)
+			return
+		}
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			updateCh <- time.Now()
+		}
+		if err := waitChild(cmd); err != nil {
+			log.Printf("upower: %s", err)
+		}
+	}()
+
+	go func() {
+		for t := range eagerTick(context.Background(), time.Minute) {
+			updateCh <- t
+		}
+	}()
+
+	go func() {
+		for c := range listen("power") {
+			if c.verb == "refresh" {
+				updateCh <- time.Now()
+			} else {
+				c.unknown()
+			}
+		}
+	}()
+
+	for range debounce(updateCh, time.Second) {
+		var b battery
+		var err error
+
+		switch config.Power.Source {
+		case "sysfs":
+			b, err = sysfsBattery("/sys/class/power_supply")
+		default:
+			b, err = acpiBattery()
+		}
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		r.sendSegment(formatBattery(b))
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatBatteryWording(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+
+	tests := []struct {
+		line        string
+		full        string
+		notCharging string
+		want        string
+	}{
+		{"Battery 0: Full, 100%", "charged", "held", "charged 100%"},
+		{"Battery 0: Full, 100%.", "charged", "held", "charged 100%"},
+		{"Battery 0: Full, 100%", "full", "held", "full 100%"},
+		{"Battery 0: Not charging, 80%", "charged", "held", "held 80%"},
+		{"Battery 0: Not charging, 80%", "charged", "capped", "capped 80%"},
+	}
+
+	for _, test := range tests {
+		config.Power.Full = test.full
+		config.Power.NotCharging = test.notCharging
+
+		b, err := parseAcpiBattery([]byte(test.line))
+		if err != nil {
+			t.Errorf("parseAcpiBattery(%q): %s", test.line, err)
+			continue
+		}
+
+		if got := formatBattery(b).Text; got != test.want {
+			t.Errorf("%q with Full %q and NotCharging %q: got %q, want %q", test.line, test.full, test.notCharging, got, test.want)
+		}
+	}
+}
+
+func TestFormatBatteryNotCharging(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+
+	b, err := parseAcpiBattery([]byte("Battery 0: Not charging, 80%\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.status != "Not charging" || b.percentage != 80 {
+		t.Fatalf("parsed %+v, want Not charging at 80%%", b)
+	}
+
+	if got, want := formatBattery(b), (Segment{Text: "held 80%"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	config.Power.Icons = true
+	want := config.Power.Glyphs[3] + config.Power.HeldGlyph + " 80%"
+	if got := formatBattery(b).Text; got != want {
+		t.Errorf("with icons got %q, want %q", got, want)
+	}
+}
+
+// writeSupply creates a fake /sys/class/power_supply entry under root.
+func writeSupply(t *testing.T, root, name string, files map[string]string) {
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for file, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(contents+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindBatteries(t *testing.T) {
+	for _, names := range [][]string{
+		{"BAT0"},
+		{"BAT1"},
+		{"CMB0"},
+		{"BAT0", "BAT1"},
+		{},
+	} {
+		root := t.TempDir()
+		writeSupply(t, root, "AC", map[string]string{"type": "Mains"})
+		for _, name := range names {
+			writeSupply(t, root, name, map[string]string{"type": "Battery"})
+		}
+
+		dirs, err := findBatteries(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, dir := range dirs {
+			got = append(got, filepath.Base(dir))
+		}
+		if strings.Join(got, ",") != strings.Join(names, ",") {
+			t.Errorf("found %q, want %q", got, names)
+		}
+	}
+}
+
+func TestSysfsBattery(t *testing.T) {
+	t.Run("energy", func(t *testing.T) {
+		root := t.TempDir()
+		writeSupply(t, root, "BAT1", map[string]string{
+			"type":        "Battery",
+			"status":      "Discharging",
+			"energy_now":  "25000000",
+			"energy_full": "50000000",
+			"power_now":   "10000000",
+		})
+
+		b, err := sysfsBattery(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := battery{status: "Discharging", percentage: 50, remaining: 150 * time.Minute, estimated: true}
+		if b != want {
+			t.Errorf("got %+v, want %+v", b, want)
+		}
+	})
+
+	t.Run("charge", func(t *testing.T) {
+		root := t.TempDir()
+		writeSupply(t, root, "CMB0", map[string]string{
+			"type":        "Battery",
+			"status":      "Charging",
+			"charge_now":  "3000000",
+			"charge_full": "4000000",
+			"current_now": "2000000",
+		})
+
+		b, err := sysfsBattery(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := battery{status: "Charging", percentage: 75, remaining: 30 * time.Minute, estimated: true}
+		if b != want {
+			t.Errorf("got %+v, want %+v", b, want)
+		}
+	})
+
+	t.Run("two batteries", func(t *testing.T) {
+		root := t.TempDir()
+		writeSupply(t, root, "BAT0", map[string]string{
+			"type":        "Battery",
+			"status":      "Full",
+			"energy_now":  "20000000",
+			"energy_full": "20000000",
+		})
+		writeSupply(t, root, "BAT1", map[string]string{
+			"type":        "Battery",
+			"status":      "Discharging",
+			"energy_now":  "10000000",
+			"energy_full": "20000000",
+			"power_now":   "0",
+		})
+
+		b, err := sysfsBattery(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := battery{status: "Discharging", percentage: 75}
+		if b != want {
+			t.Errorf("got %+v, want %+v", b, want)
+		}
+	})
+
+	t.Run("no batteries", func(t *testing.T) {
+		root := t.TempDir()
+		writeSupply(t, root, "AC", map[string]string{"type": "Mains"})
+
+		if b, err := sysfsBattery(root); err == nil {
+			t.Errorf("got %+v, want an error", b)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loadavgProcesses returns the total number of processes from the fourth
+// field of /proc/loadavg, which looks like "2/412".  It's cheap but counts
+// threads as well as processes.
+func loadavgProcesses() (int, error) {
+	data, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("/proc/loadavg has unexpected contents: %q", data)
+	}
+
+	slash := strings.IndexByte(fields[3], '/')
+	if slash < 0 {
+		return 0, fmt.Errorf("/proc/loadavg has unexpected contents: %q", data)
+	}
+
+	return strconv.Atoi(fields[3][slash+1:])
+}
+
+// countProcesses returns the number of processes by counting the numbered
+// directories in /proc.
+func countProcesses() (int, error) {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return 0, err
+	}
+	if len(dirs) == 0 {
+		return 0, errors.New("no processes in /proc")
+	}
+	return len(dirs), nil
+}
+
+// procLoop shows the number of processes.
+func procLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	for range eagerTick(context.Background(), config.Proc.Interval) {
+		var n int
+		var err error
+
+		switch config.Proc.Source {
+		case "count":
+			n, err = countProcesses()
+		default:
+			n, err = loadavgProcesses()
+		}
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		r.send(strconv.Itoa(n))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultProfile is the profile used when config.Schedule is empty.
+const defaultProfile = "day"
+
+// An activeProfile is the profile currently in effect, along with its name.
+type activeProfile struct {
+	name string
+	Profile
+}
+
+// profile holds the activeProfile that loops and render read their thresholds
+// and colors from.  The time loop switches it according to config.Schedule.
+var profile atomic.Value
+
+// currentProfile returns the profile currently in effect.
+func currentProfile() Profile {
+	return profile.Load().(activeProfile).Profile
+}
+
+// checkProfiles makes sure config.Schedule only names profiles that exist and
+// that their colors are valid.
+func checkProfiles() error {
+	if len(config.Schedule) == 0 {
+		if _, ok := config.Profiles[defaultProfile]; !ok {
+			return fmt.Errorf("no %q profile", defaultProfile)
+		}
+	}
+
+	for _, entry := range config.Schedule {
+		if entry.Hour < 0 || entry.Hour > 23 {
+			return fmt.Errorf("schedule hour %d is out of range", entry.Hour)
+		}
+		if _, ok := config.Profiles[entry.Profile]; !ok {
+			return fmt.Errorf("schedule names unknown profile %q", entry.Profile)
+		}
+	}
+
+	for name := range config.Profiles {
+		p := profileNamed(name)
+		if p.ThermalWarning <= 0 || p.ThermalCritical <= 0 {
+			return fmt.Errorf("profile %q has no thermal thresholds, and neither does %q", name, defaultProfile)
+		}
+
+		for from, to := range p.Colors {
+			if _, ok := colorEscape(from); !ok {
+				return fmt.Errorf("unknown color %q in profile %q", from, name)
+			}
+			if _, ok := colorEscape(to); !ok {
+				return fmt.Errorf("unknown color %q in profile %q", to, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// profileAt returns the name of the profile config.Schedule calls for at t:
+// the one switched to most recently, wrapping around midnight.
+func profileAt(t time.Time) string {
+	if len(config.Schedule) == 0 {
+		return defaultProfile
+	}
+
+	schedule := append([]ProfileSwitch(nil), config.Schedule...)
+	sort.Slice(schedule, func(i, j int) bool {
+		return schedule[i].Hour < schedule[j].Hour
+	})
+
+	name := schedule[len(schedule)-1].Profile
+	for _, entry := range schedule {
+		if entry.Hour <= t.Hour() {
+			name = entry.Profile
+		}
+	}
+	return name
+}
+
+// applySchedule makes the profile config.Schedule calls for at t active, if it
+// isn't already.
+func applySchedule(t time.Time) {
+	name := profileAt(t)
+
+	if current, ok := profile.Load().(activeProfile); ok {
+		if current.name == name {
+			return
+		}
+		log.Printf("Switching to the %s profile", name)
+	}
+
+	profile.Store(activeProfile{name: name, Profile: profileNamed(name)})
+}
+
+// profileNamed returns config.Profiles[name], with any thresholds it leaves
+// out taken from the default profile, so that e.g. a profile that only
+// changes colors doesn't make everything critical.
+func profileNamed(name string) Profile {
+	p := config.Profiles[name]
+	base := config.Profiles[defaultProfile]
+
+	if p.ThermalWarning == 0 {
+		p.ThermalWarning = base.ThermalWarning
+	}
+	if p.ThermalCritical == 0 {
+		p.ThermalCritical = base.ThermalCritical
+	}
+	return p
+}
+
+// recolor swaps the color escapes in text for the ones p.Colors maps them to.
+func (p Profile) recolor(text string) string {
+	if len(p.Colors) == 0 {
+		return text
+	}
+
+	var pairs []string
+	for from, to := range p.Colors {
+		fromEscape, _ := colorEscape(from)
+		toEscape, _ := colorEscape(to)
+		pairs = append(pairs, fromEscape, toEscape)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
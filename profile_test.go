@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestColorsOnlyProfileKeepsThresholds(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+
+	config.Profiles = map[string]Profile{
+		"day":   {ThermalWarning: 176, ThermalCritical: 185},
+		"night": {Colors: map[string]string{"critical": "\x05"}},
+	}
+	config.Schedule = []ProfileSwitch{{7, "day"}, {22, "night"}}
+
+	if err := checkProfiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	applySchedule(time.Date(2020, 1, 1, 23, 0, 0, 0, time.Local))
+	defer applySchedule(time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local))
+
+	if got := thermalSegment(100, "", "", false).Level; got != LevelNormal {
+		t.Errorf("100 °F at night has level %d, want normal", got)
+	}
+	if got := thermalSegment(190, "", "", false).Level; got != LevelCritical {
+		t.Errorf("190 °F at night has level %d, want critical", got)
+	}
+}
+
+func TestProfileWithoutThresholdsRejected(t *testing.T) {
+	defer func(saved Config) { config = saved }(config)
+
+	config.Profiles = map[string]Profile{
+		"day":   {},
+		"night": {Colors: map[string]string{"critical": "\x05"}},
+	}
+	config.Schedule = []ProfileSwitch{{7, "day"}, {22, "night"}}
+
+	if err := checkProfiles(); err == nil {
+		t.Error("checkProfiles accepted profiles without thermal thresholds")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "strings"
+
+// A Level says how much attention a segment needs, and picks the color it is
+// drawn in.
+type Level int
+
+const (
+	LevelNormal Level = iota
+	LevelWarning
+	LevelCritical
+)
+
+// escape returns the color escape a segment at level l starts with, or "" for
+// LevelNormal so the segment's configured color shows.
+func (l Level) escape() string {
+	switch l {
+	case LevelWarning:
+		return colorWarning
+	case LevelCritical:
+		return colorCritical
+	}
+	return ""
+}
+
+// A Segment is one update from a loop: the text to show, how urgent it is,
+// and, if non-zero, the priority it is dropped with when the status is too
+// long.  A priority set in the segment's config takes precedence.
+type Segment struct {
+	Text     string
+	Level    Level
+	Priority int
+}
+
+// textSegment turns the plain text of a loop that doesn't build Segments
+// itself into one, taking a leading warning or critical escape as its level.
+// Escapes elsewhere in text are left where they are.
+func textSegment(text string) Segment {
+	switch {
+	case strings.HasPrefix(text, colorWarning):
+		return Segment{Text: text[len(colorWarning):], Level: LevelWarning}
+	case strings.HasPrefix(text, colorCritical):
+		return Segment{Text: text[len(colorCritical):], Level: LevelCritical}
+	}
+	return Segment{Text: text}
+}
+
+// critical reports whether s is, or contains anything, drawn as critical.
+func (s Segment) critical() bool {
+	return s.Level == LevelCritical || strings.Contains(s.Text, colorCritical)
+}
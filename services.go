@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// downUnits asks systemd about each of units and returns those that aren't
+// active.
+func downUnits(units []string) ([]string, error) {
+	args := []string{"is-active"}
+	if config.Services.User {
+		args = append(args, "--user")
+	}
+	args = append(args, units...)
+
+	// is-active exits non-zero whenever any unit isn't active, so only
+	// treat it as a failure if it didn't print a state for every unit.
+	out, err := exec.Command("systemctl", args...).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+
+	states := strings.Fields(string(bytes.TrimSpace(out)))
+	if len(states) != len(units) {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("systemctl is-active returned unexpected output: %q", out)
+	}
+
+	var down []string
+	for i, state := range states {
+		if state != "active" {
+			down = append(down, units[i])
+		}
+	}
+	return down, nil
+}
+
+// servicesLoop shows "ok" while every unit in config.Services.Units is
+// active, and otherwise names the ones that aren't, colored critical.
+func servicesLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	if len(config.Services.Units) == 0 {
+		r.send("")
+		return
+	}
+
+	for range eagerTick(context.Background(), config.Services.Interval) {
+		down, err := downUnits(config.Services.Units)
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		if len(down) == 0 {
+			r.send("ok")
+			continue
+		}
+
+		names := make([]string, len(down))
+		for i, unit := range down {
+			names[i] = strings.TrimSuffix(unit, ".service") + "!"
+		}
+		r.sendSegment(Segment{Text: strings.Join(names, " "), Level: LevelCritical})
+	}
+}
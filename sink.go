@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// A Sink is somewhere the status is shown, such as the root window's name.
+type Sink interface {
+	// Show displays text as the status.
+	Show(text string) error
+
+	// String names the sink in log messages.
+	String() string
+}
+
+// newSinks builds the sinks listed in config.Sinks, or just config.Output if
+// there are none.
+func newSinks() ([]Sink, error) {
+	if len(config.Sinks) == 0 {
+		if err := checkCommand(config.Output); err != nil {
+			return nil, err
+		}
+		return []Sink{commandSink(config.Output)}, nil
+	}
+
+	sinks := make([]Sink, 0, len(config.Sinks))
+	for _, c := range config.Sinks {
+		switch c.Type {
+		case "command":
+			if err := checkCommand(c.Command); err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, commandSink(c.Command))
+		case "stdout":
+			sinks = append(sinks, stdoutSink{})
+		case "file":
+			if c.Path == "" {
+				return nil, errors.New("file sink has no path")
+			}
+			sinks = append(sinks, fileSink{path: c.Path, append: c.Append})
+		case "http":
+			if c.URL == "" {
+				return nil, errors.New("http sink has no URL")
+			}
+			sinks = append(sinks, httpSink(c.URL))
+		default:
+			return nil, fmt.Errorf("unknown sink type %q", c.Type)
+		}
+	}
+	return sinks, nil
+}
+
+// runnableSinks replaces command sinks whose command isn't installed with
+// stdout, so the status still goes somewhere instead of failing on every
+// update.  It logs each replacement once.
+func runnableSinks(sinks []Sink) []Sink {
+	haveStdout := false
+	for _, sink := range sinks {
+		if _, ok := sink.(stdoutSink); ok {
+			haveStdout = true
+		}
+	}
+
+	var runnable []Sink
+	for _, sink := range sinks {
+		command, ok := sink.(commandSink)
+		if !ok {
+			runnable = append(runnable, sink)
+			continue
+		}
+
+		if _, err := exec.LookPath(command[0]); err == nil {
+			runnable = append(runnable, sink)
+		} else if haveStdout {
+			log.Printf("%s; leaving it out", err)
+		} else {
+			log.Printf("%s; printing the status to stdout instead", err)
+			runnable = append(runnable, stdoutSink{})
+			haveStdout = true
+		}
+	}
+	return runnable
+}
+
+// checkCommand makes sure args is a usable command template.
+func checkCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("output command is empty")
+	}
+
+	for _, arg := range args {
+		if strings.Contains(arg, "%s") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("output command %q has no %%s placeholder for the status text", args)
+}
+
+// A commandSink runs a command to show the status, with %s in its arguments
+// standing for the status text, e.g. xsetroot -name %s.
+type commandSink []string
+
+func (s commandSink) Show(text string) error {
+	args := make([]string, len(s))
+	for i, arg := range s {
+		args[i] = strings.Replace(arg, "%s", text, -1)
+	}
+	return exec.Command(args[0], args[1:]...).Run()
+}
+
+func (s commandSink) String() string {
+	return s[0]
+}
+
+// A stdoutSink prints each status on its own line, e.g. for piping to a bar
+// other than dwm's.
+type stdoutSink struct{}
+
+func (stdoutSink) Show(text string) error {
+	_, err := fmt.Println(text)
+	return err
+}
+
+func (stdoutSink) String() string {
+	return "stdout"
+}
+
+// A fileSink writes the status to a file, either replacing what was there so
+// the file always holds the current status, or appending it as a log.
+type fileSink struct {
+	path   string
+	append bool
+}
+
+func (s fileSink) Show(text string) error {
+	if !s.append {
+		return ioutil.WriteFile(s.path, []byte(text+"\n"), 0644)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), text); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s fileSink) String() string {
+	return s.path
+}
+
+// An httpSink POSTs the status as plain text to a URL.
+type httpSink string
+
+// httpClient gives up on a slow server rather than holding up the bar.
+var httpClient = &http.Client{Timeout: 2 * time.Second}
+
+func (s httpSink) Show(text string) error {
+	resp, err := httpClient.Post(string(s), "text/plain; charset=utf-8", strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (s httpSink) String() string {
+	return string(s)
+}
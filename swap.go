@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+)
+
+// readSwapPages returns the number of pages swapped in and out since boot,
+// according to /proc/vmstat.
+func readSwapPages() (in, out uint64, err error) {
+	data, err := ioutil.ReadFile("/proc/vmstat")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	haveIn, haveOut := false, false
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fields := bytes.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch string(fields[0]) {
+		case "pswpin":
+			in, err = strconv.ParseUint(string(fields[1]), 10, 64)
+			haveIn = true
+		case "pswpout":
+			out, err = strconv.ParseUint(string(fields[1]), 10, 64)
+			haveOut = true
+		default:
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if !haveIn || !haveOut {
+		return 0, 0, errors.New("/proc/vmstat has no pswpin/pswpout")
+	}
+
+	return in, out, nil
+}
+
+// swapLoop shows how much swap is in use and, while the machine is paging,
+// how many pages per second are moving in and out of it.  Heavy paging is
+// colored as critical since it means the machine is thrashing.
+func swapLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	pagingFormats := formats{" %.0f pages/s", " %.0f/s"}
+
+	var lastIn, lastOut uint64
+	var lastTime time.Time
+
+	for range eagerTick(context.Background(), config.Swap.Interval) {
+		fields, err := readMeminfo()
+		if err != nil {
+			r.fail(err)
+			continue
+		}
+
+		in, out, err := readSwapPages()
+		if err != nil {
+			r.fail(err)
+			lastTime = time.Time{}
+			continue
+		}
+
+		now := time.Now()
+		var rate float64
+		if !lastTime.IsZero() {
+			rate = float64(in-lastIn+out-lastOut) / now.Sub(lastTime).Seconds()
+		}
+		lastIn, lastOut, lastTime = in, out, now
+
+		total := fields["SwapTotal"]
+		if total == 0 {
+			r.send("off")
+			continue
+		}
+
+		text := fmt.Sprintf("%.0f%%", 100*(total-fields["SwapFree"])/total)
+		if rate >= 0.5 {
+			text += pagingFormats.sprintf(rate)
+		}
+
+		segment := Segment{Text: text}
+		if rate >= config.Swap.Thrashing {
+			segment.Level = LevelCritical
+		}
+		r.sendSegment(segment)
+	}
+}
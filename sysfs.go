@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// readSysString reads a single-value file such as those under /sys or /proc,
+// without the trailing newline.
+func readSysString(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readSysInt reads a single-value file holding an integer.
+func readSysInt(path string) (int64, error) {
+	s, err := readSysString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// readSysFloat reads a single-value file holding a number.
+func readSysFloat(path string) (float64, error) {
+	s, err := readSysString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// writeTemp writes contents to a file in a fresh temporary directory and
+// returns its path.
+func writeTemp(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "value")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSysString(t *testing.T) {
+	for contents, want := range map[string]string{
+		"Battery\n":      "Battery",
+		"  Battery \n\n": "Battery",
+		"Not charging\n": "Not charging",
+		"":               "",
+	} {
+		got, err := readSysString(writeTemp(t, contents))
+		if err != nil {
+			t.Errorf("%q: %s", contents, err)
+		} else if got != want {
+			t.Errorf("%q: got %q, want %q", contents, got, want)
+		}
+	}
+}
+
+func TestReadSysInt(t *testing.T) {
+	for contents, want := range map[string]int64{
+		"45000\n":   45000,
+		" -1200 \n": -1200,
+	} {
+		got, err := readSysInt(writeTemp(t, contents))
+		if err != nil {
+			t.Errorf("%q: %s", contents, err)
+		} else if got != want {
+			t.Errorf("%q: got %d, want %d", contents, got, want)
+		}
+	}
+
+	for _, contents := range []string{"hot\n", "45.5\n", ""} {
+		if got, err := readSysInt(writeTemp(t, contents)); err == nil {
+			t.Errorf("%q: got %d, want an error", contents, got)
+		}
+	}
+}
+
+func TestReadSysFloat(t *testing.T) {
+	for contents, want := range map[string]float64{
+		"45000\n":   45000,
+		"\t12.5 \n": 12.5,
+	} {
+		got, err := readSysFloat(writeTemp(t, contents))
+		if err != nil {
+			t.Errorf("%q: %s", contents, err)
+		} else if got != want {
+			t.Errorf("%q: got %v, want %v", contents, got, want)
+		}
+	}
+
+	if got, err := readSysFloat(writeTemp(t, "n/a\n")); err == nil {
+		t.Errorf("got %v for non-numeric contents, want an error", got)
+	}
+}
+
+func TestReadSysMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing")
+
+	if _, err := readSysString(path); err == nil {
+		t.Error("readSysString succeeded on a missing file")
+	}
+	if _, err := readSysInt(path); err == nil {
+		t.Error("readSysInt succeeded on a missing file")
+	}
+	if _, err := readSysFloat(path); err == nil {
+		t.Error("readSysFloat succeeded on a missing file")
+	}
+}
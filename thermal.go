@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var acpiThermalRe = regexp.MustCompile(`Thermal 0: ok, ([.0-9]+) degrees F`)
+
+// acpiTemperature reads the first thermal zone through acpi.
+func acpiTemperature() (float64, error) {
+	out, err := remoteOutput("thermal", "acpi", "--thermal", "--fahrenheit")
+	if err != nil {
+		return 0, err
+	}
+
+	m := acpiThermalRe.FindSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("acpi returned unexpected output: %q", out)
+	}
+
+	return strconv.ParseFloat(string(m[1]), 64)
+}
+
+// hottestZone reads every thermal zone under /sys/class/thermal and returns
+// the highest temperature in degrees F along with the zone's type.  If zones
+// is non-empty, only zones whose name (thermal_zone0) or type (x86_pkg_temp)
+// appears in it are considered.
+func hottestZone(zones []string) (float64, string, error) {
+	paths, err := filepath.Glob("/sys/class/thermal/thermal_zone*/temp")
+	if err != nil {
+		return 0, "", err
+	}
+
+	var hottestF float64
+	var hottestZone string
+	var lastErr error
+	found := false
+
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		name := filepath.Base(dir)
+
+		zoneType, err := readSysString(filepath.Join(dir, "type"))
+		if err != nil {
+			zoneType = name
+		}
+
+		if len(zones) > 0 && !containsString(zones, name) && !containsString(zones, zoneType) {
+			continue
+		}
+
+		// Some zones (e.g. those of a powered-down device) fail to read;
+		// skip them rather than failing the whole segment.
+		milliC, err := readSysInt(path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		tempF := float64(milliC)/1000*9/5 + 32
+		if !found || tempF > hottestF {
+			hottestF = tempF
+			hottestZone = zoneType
+			found = true
+		}
+	}
+
+	if !found {
+		if lastErr != nil {
+			return 0, "", lastErr
+		}
+		return 0, "", errors.New("no matching thermal zones")
+	}
+
+	return hottestF, hottestZone, nil
+}
+
+// sensorsTemperature parses the output of sensors -u for the temperature in
+// degrees F of feature, such as "Package id 0", on the first chip whose name
+// starts with chip, such as "coretemp".  An empty chip matches any.
+func sensorsTemperature(out []byte, chip, feature string) (float64, error) {
+	var currentChip, currentFeature string
+	newChip := true
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			newChip = true
+		case newChip:
+			// Each chip's block starts with its name, then its adapter.
+			currentChip, currentFeature = line, ""
+			newChip = false
+		case !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":"):
+			currentFeature = strings.TrimSuffix(line, ":")
+		case strings.HasPrefix(currentChip, chip) && currentFeature == feature:
+			fields := strings.SplitN(strings.TrimSpace(line), ":", 2)
+			if len(fields) != 2 || !strings.HasPrefix(fields[0], "temp") || !strings.HasSuffix(fields[0], "_input") {
+				continue
+			}
+
+			tempC, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+			if err != nil {
+				return 0, err
+			}
+			return tempC*9/5 + 32, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("sensors reports no temperature for %q on chip %q", feature, chip)
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// trendArrow returns an arrow showing whether cur has risen or fallen from
+// prev by more than deadband.
+func trendArrow(prev, cur, deadband float64) string {
+	if cur-prev > deadband {
+		return "\u2191"
+	} else if prev-cur > deadband {
+		return "\u2193"
+	}
+	return "\u2192"
+}
+
+// A latch fires once each time a value reaches its limit, and re-arms once the
+// value drops back below it.
+type latch struct {
+	limit float64
+	high  bool
+}
+
+// crossed reports whether v has just reached the latch's limit.
+func (l *latch) crossed(v float64) bool {
+	if v < l.limit {
+		l.high = false
+		return false
+	}
+	if l.high {
+		return false
+	}
+	l.high = true
+	return true
+}
+
+// runEmergencyCommand starts config.Thermal.EmergencyCommand without waiting
+// for it to finish.  When the temperature comes from a remote host, that's
+// where the command runs too, since that's the machine overheating.
+func runEmergencyCommand(tempF float64) {
+	args := config.Thermal.EmergencyCommand
+	log.Printf("Temperature %.1f \u00b0F reached the emergency limit, running %q", tempF, args)
+
+	cmd := remoteCommand(context.Background(), "thermal", args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		log.Print(err)
+		return
+	}
+
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Printf("%s: %s", args[0], err)
+		}
+	}()
+}
+
+// readTemperature reads the temperature in degrees F from the configured
+// source, along with the name of the zone or sensor it came from, if known.
+func readTemperature() (tempF float64, zone string, err error) {
+	switch config.Thermal.Source {
+	case "zones":
+		return hottestZone(config.Thermal.Zones)
+	case "sensors":
+		out, err := remoteOutput("thermal", "sensors", "-u")
+		if err != nil {
+			return 0, "", err
+		}
+		tempF, err = sensorsTemperature(out, config.Thermal.Chip, config.Thermal.Feature)
+		return tempF, config.Thermal.Feature, err
+	default:
+		tempF, err = acpiTemperature()
+		return tempF, "", err
+	}
+}
+
+// thermalSegment renders a reading as thermalLoop's segment, in degrees C if
+// celsius is set.  Thresholds are always in degrees F.
+func thermalSegment(tempF float64, zone, trend string, celsius bool) Segment {
+	thermalFormats := formats{"%s%.1f %s %s", "%s%.0[2]f\u00b0%[4]s"}
+
+	label := ""
+	if config.Thermal.ShowZone && zone != "" {
+		label = zone + " "
+	}
+
+	temp, unit := tempF, "\u00b0F"
+	if celsius {
+		temp, unit = (tempF-32)*5/9, "\u00b0C"
+	}
+
+	segment := Segment{Text: thermalFormats.sprintf(label, temp, unit, trend)}
+	if p := currentProfile(); tempF >= p.ThermalCritical {
+		segment.Level = LevelCritical
+	} else if tempF >= p.ThermalWarning {
+		segment.Level = LevelWarning
+	}
+	return segment
+}
+
+// thermalLoop shows the temperature, switching between degrees F and C each
+// time it gets a "toggle thermal" command, which main also sends on SIGUSR1.
+func thermalLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	var lastF float64
+	haveLast := false
+	band := deadband{width: config.Thermal.Deadband}
+	emergency := latch{limit: config.Thermal.EmergencyLimit}
+
+	celsius := config.Thermal.Celsius
+
+	// shown is the reading on the bar, if it isn't showing an error, so a
+	// toggle can redraw it in the other unit straight away.
+	var shownF float64
+	var shownZone, shownTrend string
+	shown := false
+
+	// Reading a remote host over a connection that's down fails slowly, so
+	// keep showing the last reading for a while and try less often.
+	last := cache{}
+	if len(config.Remote["thermal"]) > 0 {
+		last.maxAge = config.RemoteMaxAge
+	}
+	retry := backoff{min: 5 * time.Second, max: time.Minute}
+
+	toggle := func() {
+		celsius = !celsius
+		if shown {
+			r.sendSegment(thermalSegment(shownF, shownZone, shownTrend, celsius))
+		}
+	}
+
+	commands := listen("thermal")
+	ticks := eagerTick(context.Background(), time.Second)
+
+	for {
+		select {
+		case c := <-commands:
+			switch c.verb {
+			case "toggle":
+				toggle()
+				continue
+			case "refresh":
+				// Read the temperature now, as if it were a tick.
+			default:
+				c.unknown()
+				continue
+			}
+		case now := <-ticks:
+			if !retry.ready(now) {
+				continue
+			}
+		}
+
+		tempF, zone, err := readTemperature()
+		if err != nil {
+			if last.maxAge > 0 {
+				retry.failed(time.Now())
+			}
+
+			// Whatever is shown now, stale or "(err)", the next reading
+			// has to replace it even if it's within the deadband.
+			r.failCached(err, &last)
+			band.reset()
+			shown = false
+			continue
+		}
+		retry.succeeded()
+
+		if len(config.Thermal.EmergencyCommand) > 0 && emergency.crossed(tempF) {
+			runEmergencyCommand(tempF)
+		}
+
+		trend := "\u2192"
+		if haveLast {
+			trend = trendArrow(lastF, tempF, config.Thermal.TrendDeadband)
+		}
+		lastF, haveLast = tempF, true
+
+		if !band.changed(tempF) {
+			continue
+		}
+
+		shownF, shownZone, shownTrend, shown = tempF, zone, trend, true
+		segment := thermalSegment(tempF, zone, trend, celsius)
+		last.store(segment)
+		r.sendSegment(segment)
+	}
+}
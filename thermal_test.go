@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestLatchFiresOncePerCrossing(t *testing.T) {
+	l := latch{limit: 203}
+
+	steps := []struct {
+		v    float64
+		want bool
+	}{
+		{190, false},
+		{203, true},
+		{210, false},
+		{205, false},
+		{190, false},
+		{203, true},
+		{203, false},
+	}
+
+	for i, step := range steps {
+		if got := l.crossed(step.v); got != step.want {
+			t.Errorf("step %d: crossed(%v) = %v, want %v", i, step.v, got, step.want)
+		}
+	}
+}
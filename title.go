@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	activeWindowRe = regexp.MustCompile(`^_NET_ACTIVE_WINDOW\(WINDOW\): window id # (0x[0-9a-f]+)`)
+	windowNameRe   = regexp.MustCompile(`^(_NET_WM_NAME|WM_NAME)\(\w+\) = (".*")$`)
+)
+
+// spy runs xprop -spy with args and sends each line it prints to lines until
+// it exits, then closes lines.
+func spy(lines chan<- string, args ...string) (*exec.Cmd, error) {
+	cmd := exec.Command("xprop", append([]string{"-spy"}, args...)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := startChild(cmd); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		if err := waitChild(cmd); err != nil {
+			log.Printf("xprop: %s", err)
+		}
+	}()
+
+	return cmd, nil
+}
+
+// cleanTitle unquotes a window name as printed by xprop and strips control
+// characters, which dwm would otherwise take as color escapes.
+func cleanTitle(quoted string) string {
+	title, err := strconv.Unquote(quoted)
+	if err != nil {
+		title = strings.Trim(quoted, `"`)
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return -1
+		}
+		return r
+	}, title)
+}
+
+// titleLoop shows the title of the focused window.  Rather than polling, it
+// has xprop report changes to the root window's _NET_ACTIVE_WINDOW and to the
+// focused window's name as X sends PropertyNotify events for them.
+func titleLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	activeCh := make(chan string)
+	if _, err := spy(activeCh, "-root", "_NET_ACTIVE_WINDOW"); err != nil {
+		r.fail(err)
+		return
+	}
+
+	var window *exec.Cmd
+	var nameCh chan string
+
+	// A window without _NET_WM_NAME may still have the older WM_NAME.
+	var netName, name string
+	show := func() {
+		title := netName
+		if title == "" {
+			title = name
+		}
+		if config.Title.MaxLength > 0 {
+			title = truncate(title, config.Title.MaxLength)
+		}
+		r.send(title)
+	}
+
+	for {
+		select {
+		case line, ok := <-activeCh:
+			if !ok {
+				r.fail(errors.New("xprop stopped reporting the active window"))
+				return
+			}
+
+			m := activeWindowRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			if window != nil {
+				window.Process.Kill()
+				// Throw away whatever the old spy printed before it died,
+				// so it can exit.
+				go func(lines <-chan string) {
+					for range lines {
+					}
+				}(nameCh)
+				window, nameCh = nil, nil
+			}
+			netName, name = "", ""
+
+			if m[1] == "0x0" {
+				r.send("")
+				continue
+			}
+
+			nameCh = make(chan string)
+			var err error
+			window, err = spy(nameCh, "-id", m[1], "_NET_WM_NAME", "WM_NAME")
+			if err != nil {
+				r.fail(err)
+				window, nameCh = nil, nil
+			}
+		case line, ok := <-nameCh:
+			if !ok {
+				// The window went away; the next _NET_ACTIVE_WINDOW
+				// change will tell us what's focused now.
+				window, nameCh = nil, nil
+				continue
+			}
+
+			m := windowNameRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			if m[1] == "_NET_WM_NAME" {
+				netName = cleanTitle(m[2])
+			} else {
+				name = cleanTitle(m[2])
+			}
+			show()
+		}
+	}
+}
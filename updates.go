@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// countUpdates runs config.Updates.Command and counts the lines of its output
+// that match config.Updates.Match.
+func countUpdates() (int, error) {
+	args := config.Updates.Command
+	if len(args) == 0 {
+		return 0, errors.New("no command to check for updates with")
+	}
+	out, err := exec.Command(args[0], args[1:]...).Output()
+
+	// checkupdates exits with status 2 when there is nothing to update.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 && len(bytes.TrimSpace(out)) == 0 {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var re *regexp.Regexp
+	if config.Updates.Match != "" {
+		re, err = regexp.Compile(config.Updates.Match)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if re != nil && !re.Match(line) {
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// updatesLoop shows how many package updates are available, or nothing if
+// the system is up to date.  The check can take a while, but since it runs in
+// this loop's own goroutine it doesn't hold up any other segment.
+func updatesLoop(ch chan<- Segment) {
+	r := reporter{ch: ch}
+
+	// Checking usually needs the network, so ride out a failed check by
+	// showing the last count for a while.
+	last := cache{maxAge: config.Updates.MaxAge}
+
+	for range eagerTick(context.Background(), config.Updates.Interval) {
+		count, err := countUpdates()
+		if err != nil {
+			r.failCached(err, &last)
+			continue
+		}
+
+		segment := Segment{}
+		if count > 0 {
+			segment.Text = strconv.Itoa(count)
+		}
+		last.store(segment)
+		r.sendSegment(segment)
+	}
+}